@@ -0,0 +1,78 @@
+package yabre
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ExecutionMode selects how the conditions of a rule set are run
+type ExecutionMode string
+
+const (
+	// ModeFlow runs conditions as a flowchart, from the default condition following `next`; it is the default mode
+	ModeFlow ExecutionMode = "flow"
+	// ModeAgenda runs conditions as forward chaining rules: the condition with the highest salience whose check is true
+	// fires its true branch, then all conditions are evaluated again, until none fires or a true branch terminates
+	ModeAgenda ExecutionMode = "agenda"
+)
+
+// salienceOrder returns the conditions highest salience first, by name on ties
+func (r *Rules) salienceOrder() []*Condition {
+	conditions := make([]*Condition, 0, len(r.Conditions))
+	for name := range r.Conditions {
+		condition := r.Conditions[name]
+		conditions = append(conditions, &condition)
+	}
+	sort.Slice(conditions, func(i, j int) bool {
+		if conditions[i].Salience != conditions[j].Salience {
+			return conditions[i].Salience > conditions[j].Salience
+		}
+		return conditions[i].Name < conditions[j].Name
+	})
+	return conditions
+}
+
+// runAgenda fires the conditions of an agenda rule set until none fires.
+// Every evaluation of a check counts against the step and visit budgets of the runner,
+// so rules whose checks stay true stop with a *CycleError.
+func (exec *execution[Context]) runAgenda() error {
+	agenda := exec.rules.salienceOrder()
+	for {
+		fired, err := exec.fire(agenda)
+		if err != nil {
+			exec.trace.record(exec.errorEvent(err))
+			return fmt.Errorf("error while evaluating condition '%s': %w", exec.current.Name, err)
+		}
+		if fired == nil {
+			exec.runner.decisionCallback("No condition fired, terminating")
+			exec.current = nil
+			exec.trace.record(exec.event(TraceTerminate, TraceEvent{}))
+			return nil
+		}
+		if fired.True.Terminate {
+			exec.runner.decisionCallback("Terminating")
+			exec.trace.record(exec.event(TraceTerminate, TraceEvent{Decision: fired.True.Name}))
+			return nil
+		}
+	}
+}
+
+// fire evaluates the conditions in agenda order and fires the true branch of the first true one, if any
+func (exec *execution[Context]) fire(agenda []*Condition) (*Condition, error) {
+	for _, condition := range agenda {
+		decision, _, err := exec.evaluate(condition)
+		if err != nil {
+			return nil, err
+		}
+		if decision == nil || decision != condition.True {
+			continue
+		}
+
+		exec.runner.decisionCallback("Firing condition: [%s]", condition.Name)
+		if err := exec.act(decision); err != nil {
+			return nil, err
+		}
+		return condition, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,160 @@
+package yabre
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/dop251/goja"
+)
+
+// ContextChange is a context field changed by the action or `set` assignments of a decision
+type ContextChange struct {
+	// Path of the changed field, e.g. `order.items[2].price`, using the field names seen by the rules
+	Path string `json:"path"`
+	// Old is the value before the decision, nil if the field has been added
+	Old interface{} `json:"old,omitempty"`
+	// New is the value after the decision, nil if the field has been removed
+	New interface{} `json:"new,omitempty"`
+	// Condition and Decision are responsible for the change; Decision is empty for the outputs of a decision table
+	Condition string `json:"condition,omitempty"`
+	Decision  string `json:"decision,omitempty"`
+}
+
+func (c ContextChange) String() string {
+	return fmt.Sprintf("%s: %v -> %v (%s)", c.Path, c.Old, c.New, ifEmpty(c.Decision, c.Condition))
+}
+
+// WithContextAudit snapshots the context before and after the action and `set` assignments of every decision,
+// and around the evaluation of decision tables, and reports the changed fields to the callback, if not nil,
+// and in the `Changes` of trace action events, or of result events for decision tables.
+// Snapshots walk the whole context, so auditing slows runs down with large contexts.
+func WithContextAudit[Context interface{}](callback func(changes []ContextChange)) WithOption[Context] {
+	return func(runner *RulesRunner[Context]) error {
+		runner.audit = true
+		runner.auditCallback = callback
+		return nil
+	}
+}
+
+// maxSnapshotDepth bounds the nesting of context snapshots, so reference cycles in Go contexts don't recurse forever
+const maxSnapshotDepth = 32
+
+// snapshot copies the javascript context into plain maps, slices and values that can be compared after an action
+func (exec *execution[Context]) snapshot() interface{} {
+	return snapshotValue(exec.vm.Get("context"), 0)
+}
+
+func snapshotValue(value goja.Value, depth int) interface{} {
+	if value == nil {
+		return nil
+	}
+	object, ok := value.(*goja.Object)
+	if !ok {
+		return value.Export()
+	}
+	if depth >= maxSnapshotDepth {
+		return nil
+	}
+
+	if object.ClassName() == "Array" {
+		length := int(object.Get("length").ToInteger())
+		items := make([]interface{}, length)
+		for i := range items {
+			items[i] = snapshotValue(object.Get(strconv.Itoa(i)), depth+1)
+		}
+		return items
+	}
+
+	fields := map[string]interface{}{}
+	for _, key := range object.Keys() {
+		field := object.Get(key)
+		if _, isFunction := goja.AssertFunction(field); isFunction {
+			continue
+		}
+		fields[key] = snapshotValue(field, depth+1)
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+
+	// objects without fields, like dates, are compared by their value
+	exported := reflect.ValueOf(object.Export())
+	if exported.Kind() == reflect.Pointer && !exported.IsNil() {
+		return exported.Elem().Interface()
+	}
+	return object.Export()
+}
+
+// changes compares the context with its snapshot taken before a decision, or before the decision table of the current
+// condition when decision is empty, and reports the changes to the audit callback
+func (exec *execution[Context]) changes(decision string, before interface{}) []ContextChange {
+	changes := diffSnapshots("", before, exec.snapshot())
+	if len(changes) == 0 {
+		return nil
+	}
+	for i := range changes {
+		changes[i].Decision = decision
+		if exec.current != nil {
+			changes[i].Condition = exec.current.Name
+		}
+	}
+	if exec.runner.auditCallback != nil {
+		exec.runner.auditCallback(changes)
+	}
+	return changes
+}
+
+// diffSnapshots returns the changes between two snapshots of a context, ordered by path
+func diffSnapshots(path string, before, after interface{}) []ContextChange {
+	oldFields, oldIsObject := before.(map[string]interface{})
+	newFields, newIsObject := after.(map[string]interface{})
+	if oldIsObject && newIsObject {
+		keys := make([]string, 0, len(oldFields)+len(newFields))
+		for key := range oldFields {
+			keys = append(keys, key)
+		}
+		for key := range newFields {
+			if _, ok := oldFields[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		changes := []ContextChange{}
+		for _, key := range keys {
+			changes = append(changes, diffSnapshots(joinPath(path, key), oldFields[key], newFields[key])...)
+		}
+		return changes
+	}
+
+	oldItems, oldIsArray := before.([]interface{})
+	newItems, newIsArray := after.([]interface{})
+	if oldIsArray && newIsArray {
+		changes := []ContextChange{}
+		for i := 0; i < len(oldItems) || i < len(newItems); i++ {
+			var oldItem, newItem interface{}
+			if i < len(oldItems) {
+				oldItem = oldItems[i]
+			}
+			if i < len(newItems) {
+				newItem = newItems[i]
+			}
+			changes = append(changes, diffSnapshots(fmt.Sprintf("%s[%d]", path, i), oldItem, newItem)...)
+		}
+		return changes
+	}
+
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	return []ContextChange{{Path: path, Old: before, New: after}}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,154 @@
+package yabre
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner_ContextAudit(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/shipping_expr.yaml")
+	require.NoError(t, err)
+
+	var audited [][]ContextChange
+	rulesContext := ShippingContext{Weight: 200, Express: true, Notes: []string{}}
+	runner, err := NewRulesRunnerFromYaml(yamlFile, &rulesContext,
+		WithContextAudit[ShippingContext](func(changes []ContextChange) {
+			audited = append(audited, changes)
+		}))
+	require.NoError(t, err)
+
+	_, trace, err := runner.RunRulesWithTrace(context.Background(), &rulesContext, nil)
+	require.NoError(t, err)
+
+	expected := [][]ContextChange{
+		{
+			{Path: "Cost", Old: int64(0), New: int64(20), Condition: "check_weight", Decision: "check_weight_true"},
+			{Path: "Method", Old: "", New: "parcel", Condition: "check_weight", Decision: "check_weight_true"},
+		},
+		{
+			{Path: "Cost", Old: int64(20), New: int64(40), Condition: "check_express", Decision: "check_express_true"},
+			{Path: "Notes[0]", New: "express", Condition: "check_express", Decision: "check_express_true"},
+			{Path: "Tracking", Old: false, New: true, Condition: "check_express", Decision: "check_express_true"},
+		},
+	}
+	assert.Equal(t, expected, audited)
+
+	actions := [][]ContextChange{}
+	for _, event := range trace.Events {
+		if event.Type == TraceAction {
+			actions = append(actions, event.Changes)
+		}
+	}
+	assert.Equal(t, expected, actions)
+}
+
+func TestRunner_ContextAudit_Tables(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/pricing_tables.yaml")
+	require.NoError(t, err)
+
+	var audited [][]ContextChange
+	rulesContext := PricingContext{CustomerType: "consumer", Total: 600, Express: true, Country: "FR"}
+	runner, err := NewRulesRunnerFromYaml(yamlFile, &rulesContext,
+		WithContextAudit[PricingContext](func(changes []ContextChange) {
+			audited = append(audited, changes)
+		}))
+	require.NoError(t, err)
+
+	_, trace, err := runner.RunRulesWithTrace(context.Background(), &rulesContext, nil)
+	require.NoError(t, err)
+
+	// the outputs of decision tables are attributed to their condition
+	expected := [][]ContextChange{
+		{
+			{Path: "Discount", Old: int64(0), New: 0.05, Condition: "apply_discount"},
+			{Path: "Reason", Old: "", New: "order over 500", Condition: "apply_discount"},
+		},
+		{
+			{Path: "Surcharges[0]", New: "express", Condition: "apply_surcharges"},
+			{Path: "Surcharges[1]", New: "international", Condition: "apply_surcharges"},
+		},
+	}
+	assert.Equal(t, expected, audited)
+
+	results := [][]ContextChange{}
+	for _, event := range trace.Events {
+		if event.Type == TraceResult {
+			results = append(results, event.Changes)
+		}
+	}
+	assert.Equal(t, expected, results)
+	assert.Equal(t, "Discount: 0 -> 0.05 (apply_discount)", audited[0][0].String())
+}
+
+func TestRunner_ContextAudit_Objects(t *testing.T) {
+	yamlRules := `
+name: audit-objects
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      action: |
+        function() {
+          context.customer.tier = "gold";
+          context.customer.since = undefined;
+          context.flags = {vip: true};
+          context.items.pop();
+          delete context.obsolete;
+        }
+      terminate: true
+`
+	rulesContext := map[string]interface{}{
+		"customer": map[string]interface{}{"name": "ada", "since": 2020},
+		"items":    []interface{}{"a", "b"},
+		"obsolete": true,
+	}
+
+	var audited []ContextChange
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext,
+		WithContextAudit[map[string]interface{}](func(changes []ContextChange) {
+			audited = append(audited, changes...)
+		}))
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+
+	paths := map[string][2]interface{}{}
+	for _, change := range audited {
+		paths[change.Path] = [2]interface{}{change.Old, change.New}
+	}
+	assert.Equal(t, map[string][2]interface{}{
+		"customer.since": {int64(2020), nil},
+		"customer.tier":  {nil, "gold"},
+		"flags":          {nil, map[string]interface{}{"vip": true}},
+		"items[1]":       {"b", nil},
+		"obsolete":       {true, nil},
+	}, paths)
+}
+
+func TestRunner_ContextAudit_NoChanges(t *testing.T) {
+	yamlRules := `
+name: audit-none
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      action: "function() { var total = context.total * 2; }"
+      terminate: true
+`
+	rulesContext := map[string]interface{}{"total": 1}
+	called := false
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext,
+		WithContextAudit[map[string]interface{}](func(changes []ContextChange) { called = true }))
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+	assert.False(t, called)
+}
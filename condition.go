@@ -1,8 +1,13 @@
 package yabre
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dop251/goja"
 )
@@ -14,6 +19,33 @@ type Condition struct {
 	Check       string    `yaml:"check"`
 	True        *Decision `yaml:"true"`
 	False       *Decision `yaml:"false"`
+	// Cases make the condition a switch: the value returned by the check selects the decision,
+	// falling back to the `default` case when no case matches
+	Cases map[string]*Decision `yaml:"cases,omitempty"`
+	// Expr is a javascript expression evaluated as the check, for conditions without a check function
+	Expr string `yaml:"expr,omitempty"`
+	// Table is the name of a decision table evaluated as the check; the condition is true if any row matched
+	Table string `yaml:"table,omitempty"`
+	// File is the path of the file the condition was loaded from, if loaded from a library
+	File string `yaml:"-"`
+	// RuleSet is the name of the rule set the condition was loaded from, if loaded from a library
+	RuleSet string `yaml:"-"`
+	// Private conditions can't be the next condition of conditions from other rule sets
+	Private bool `yaml:"private,omitempty"`
+	// Namespace is the name of the namespaced rule set the condition was merged from;
+	// the condition name is then qualified as `<namespace>.<condition>`
+	Namespace string `yaml:"-"`
+	// Salience orders the conditions of agenda rule sets: conditions with a higher salience fire first
+	Salience int `yaml:"salience,omitempty"`
+	// Groups tag the condition, so Evaluate can evaluate the conditions of some groups only
+	Groups []string `yaml:"groups,omitempty"`
+	// OnError is the decision taken when the check or the decision of the condition fails,
+	// instead of the on_error decision of the rules or aborting the run
+	OnError *Decision `yaml:"on_error,omitempty"`
+	// Position is the location of the condition in its rules file, if loaded from YAML
+	Position Position `yaml:"-"`
+	// position of the check or expr javascript in the rules file
+	source Position
 }
 
 type Decision struct {
@@ -23,6 +55,109 @@ type Decision struct {
 	Next        string `yaml:"next"`
 	Terminate   bool   `yaml:"terminate"`
 	Value       bool   `yaml:"-"`
+	// Call runs the default condition of the named rule set before moving on to Next or terminating
+	Call string `yaml:"call,omitempty"`
+	// Case is the value selecting the decision of a switch condition
+	Case string `yaml:"-"`
+	// Set assigns values to context fields after the action has run. Fields are dotted paths relative to the context;
+	// values are literals, or javascript expressions when they are strings starting with `=`
+	Set map[string]interface{} `yaml:"set,omitempty"`
+	// Retry is the number of times an on_error decision evaluates the failing condition again before handling the error
+	Retry int `yaml:"retry,omitempty"`
+	// Position is the location of the decision in its rules file, if loaded from YAML
+	Position Position `yaml:"-"`
+	// position of the action javascript in the rules file
+	source Position
+	// errorHandler is true for on_error decisions, whose action and assignments receive the handled error as `error`
+	errorHandler bool
+}
+
+// DefaultCase is the case of a switch condition taken when no other case matches
+const DefaultCase = "default"
+
+// isSwitch reports whether the condition selects its decision by case instead of true/false
+func (c *Condition) isSwitch() bool {
+	return len(c.Cases) > 0
+}
+
+// decisions returns the defined decisions of the condition: its true and false branches or its cases ordered by value,
+// then its on_error decision
+func (c *Condition) decisions() []*Decision {
+	decisions := []*Decision{}
+	for _, decision := range []*Decision{c.True, c.False} {
+		if decision != nil {
+			decisions = append(decisions, decision)
+		}
+	}
+
+	values := make([]string, 0, len(c.Cases))
+	for value, decision := range c.Cases {
+		if decision != nil {
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		decisions = append(decisions, c.Cases[value])
+	}
+
+	if c.OnError != nil {
+		decisions = append(decisions, c.OnError)
+	}
+
+	return decisions
+}
+
+// nameDecisions names the decisions after the condition: `<condition>_true`, `<condition>_false` or `<condition>_<case>`
+func (c *Condition) nameDecisions() {
+	if c.True != nil {
+		c.True.Name = c.Name + "_true"
+		c.True.Value = true
+	}
+
+	if c.False != nil {
+		c.False.Name = c.Name + "_false"
+		c.False.Value = false
+	}
+
+	for value, decision := range c.Cases {
+		if decision != nil {
+			decision.Name = c.Name + "_" + value
+			decision.Case = value
+		}
+	}
+
+	if c.OnError != nil {
+		c.OnError.Name = c.Name + "_on_error"
+		c.OnError.errorHandler = true
+	}
+}
+
+// sourcePrefix returns the number of characters generated before the javascript of the check function on its first line
+func (c *Condition) sourcePrefix() int {
+	if c.Check == "" && c.Expr != "" {
+		return len(exprPrefix)
+	}
+	return 0
+}
+
+// label names the branch of the decision in messages: `true`, `false`, `case '<value>'` or `on_error`
+func (d *Decision) label() string {
+	if d.errorHandler {
+		return "on_error"
+	}
+	if d.Case != "" {
+		return fmt.Sprintf("case '%s'", d.Case)
+	}
+	return strconv.FormatBool(d.Value)
+}
+
+// params returns the parameters of the action and set functions of the decision: on_error decisions receive the error
+func (d *Decision) params() []string {
+	if d.errorHandler {
+		return []string{"error"}
+	}
+	return nil
 }
 
 func (cr *Decision) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -36,83 +171,359 @@ func (cr *Decision) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return errors.New("next and terminate cannot be used together")
 	}
 
+	if dsn.Retry < 0 {
+		return errors.New("retry can't be negative")
+	}
+
+	if _, err := setFunction(dsn.Set); err != nil {
+		return fmt.Errorf("invalid set: %w", err)
+	}
+
 	*cr = Decision(dsn)
 	return nil
 }
 
-// Run the conditions recursively
-func (runner *RulesRunner[Context]) runCondition(vm *goja.Runtime, rules *Rules, condition *Condition) error {
-	runner.decisionCallback("Evaluating condition: [%s] %s", condition.Name, condition.Description)
+// execution holds the state of a single rules run
+type execution[Context interface{}] struct {
+	runner *RulesRunner[Context]
+	ctx    context.Context
+	vm     *goja.Runtime
+	rules  *Rules
+	// check and action functions of the run
+	functions registry
+	// number of conditions evaluated so far
+	steps int
+	// number of times each condition has been evaluated
+	visits map[string]int
+	// names of evaluated conditions in order of evaluation; only kept when a budget is set
+	path []string
+	// records the execution when requested
+	trace *Trace
+	// condition being evaluated
+	current *Condition
+	// rule set calls in progress, innermost last
+	stack []callFrame
+}
+
+// callFrame is a rule set call waiting for the called rule set to finish
+type callFrame struct {
+	ruleSet  string
+	caller   *Condition
+	decision *Decision
+}
+
+func (runner *RulesRunner[Context]) newExecution(vm *goja.Runtime, functions registry, rules *Rules) *execution[Context] {
+	return &execution[Context]{
+		runner:    runner,
+		ctx:       context.Background(),
+		vm:        vm,
+		rules:     rules,
+		functions: functions,
+		visits:    map[string]int{},
+	}
+}
+
+// run evaluates conditions iteratively, so long chains of `next` don't consume Go stack
+func (exec *execution[Context]) run(condition *Condition) error {
+	first := true
+	for condition != nil {
+		next, err := exec.runGuarded(condition)
+		// a called rule set has finished, continue with the decision that called it
+		for err == nil && next == nil && len(exec.stack) > 0 {
+			next, err = exec.returnFromCall()
+		}
+		if err != nil {
+			exec.trace.record(exec.errorEvent(err))
+			if len(exec.stack) > 0 {
+				frames := []string{}
+				for _, frame := range exec.callStack() {
+					frames = append(frames, frame.String())
+				}
+				return fmt.Errorf("error while evaluating condition '%s' (call stack: %s): %w", exec.current.Name, strings.Join(frames, ", "), err)
+			}
+			if first {
+				return err
+			}
+			return fmt.Errorf("error while evaluating condition '%s': %w", exec.current.Name, err)
+		}
+		condition = next
+		first = false
+	}
+	return nil
+}
+
+// runCondition evaluates a single condition and its decision and returns the next condition to evaluate
+func (exec *execution[Context]) runCondition(condition *Condition) (*Condition, error) {
+	decision, _, err := exec.evaluate(condition)
+	if err != nil {
+		return nil, err
+	}
+
+	if decision == nil {
+		exec.runner.decisionCallback("No action or next condition defined, terminating")
+		exec.trace.record(exec.event(TraceTerminate, TraceEvent{}))
+		return nil, nil
+	}
+
+	return exec.decide(decision)
+}
+
+// evaluate runs the check of a condition and returns the decision it selects, if defined, along with the check result:
+// the value selecting the case of a switch or a bool
+func (exec *execution[Context]) evaluate(condition *Condition) (*Decision, interface{}, error) {
+	runner := exec.runner
 
-	// Get the custom function name for the check function
-	checkFuncName := runner.getFunctionName(condition.Name)
+	exec.current = condition
+	if err := exec.step(condition); err != nil {
+		return nil, nil, err
+	}
+
+	runner.decisionCallback("Evaluating condition: [%s] %s", condition.Name, condition.Description)
+	exec.trace.record(exec.event(TraceCondition, TraceEvent{}))
 
 	// Evaluate the check function
-	checkFunc, ok := goja.AssertFunction(vm.Get(checkFuncName))
+	check, ok := exec.functions[condition.Name]
 	if !ok {
-		return fmt.Errorf("check function not found: %s", checkFuncName)
+		return nil, nil, &FunctionNotFoundError{Kind: "check", Name: condition.Name}
+	}
+	// decision tables set their outputs on the context while being evaluated
+	var before interface{}
+	audited := runner.audit && condition.Table != ""
+	if audited {
+		before = exec.snapshot()
 	}
-	checkResult, err := checkFunc(goja.Undefined())
+	start := time.Now()
+	checkResult, err := check.fn(goja.Undefined())
 	if err != nil {
-		return fmt.Errorf("error evaluating check function %s: %w", checkFuncName, err)
+		scriptErr := exec.rules.locateError(newScriptError(err, condition.File, condition.Name, ""), condition.Name, condition.source, condition.sourcePrefix(), condition.Position)
+		return nil, nil, fmt.Errorf("error evaluating check function %s: %w", check.name, scriptErr)
 	}
 
-	if checkResult.ToBoolean() {
-		runner.decisionCallback("Condition [%s] evaluated to [true]", condition.Name)
-		if condition.True == nil {
-			runner.decisionCallback("No action or next condition defined, terminating")
-			return nil
+	var decision *Decision
+	var result interface{}
+	if condition.isSwitch() {
+		value := checkResult.String()
+		result = checkResult.Export()
+		runner.decisionCallback("Condition [%s] evaluated to [%s]", condition.Name, value)
+		var ok bool
+		if decision, ok = condition.Cases[value]; !ok {
+			decision = condition.Cases[DefaultCase]
 		}
-		return runner.runAction(vm, rules, condition.True)
+	} else if checkResult.ToBoolean() {
+		result = true
+		runner.decisionCallback("Condition [%s] evaluated to [true]", condition.Name)
+		decision = condition.True
 	} else {
+		result = false
 		runner.decisionCallback("Condition [%s] evaluated to [false]", condition.Name)
-		if condition.False == nil {
-			runner.decisionCallback("No action or next condition defined, terminating")
-			return nil
-		}
-		return runner.runAction(vm, rules, condition.False)
+		decision = condition.False
+	}
+	duration := time.Since(start)
+
+	var changes []ContextChange
+	if audited {
+		changes = exec.changes("", before)
+	}
+	exec.trace.record(exec.event(TraceResult, TraceEvent{Result: result, Duration: duration, Changes: changes}))
+
+	return decision, result, nil
+}
+
+// decide runs the decision action and resolves the next condition, if any
+func (exec *execution[Context]) decide(result *Decision) (*Condition, error) {
+	if err := exec.act(result); err != nil {
+		return nil, err
+	}
+
+	if result.Call != "" {
+		return exec.call(result)
 	}
+
+	return exec.proceed(result)
 }
 
-// Helper function to run the action
-func (runner *RulesRunner[Context]) runAction(vm *goja.Runtime, rules *Rules, result *Decision) error {
-	if result.Action != "" {
-		actionFuncName := runner.getFunctionName(result.Name)
-		runner.decisionCallback("Running action: [%s] %s", actionFuncName, result.Description)
-		actionFunc, ok := goja.AssertFunction(vm.Get(actionFuncName))
-		if !ok {
-			return fmt.Errorf("action function not found: %s", actionFuncName)
+// act runs the action and the context assignments of a decision, passing them the arguments, if any
+func (exec *execution[Context]) act(result *Decision, args ...goja.Value) error {
+	runner := exec.runner
+
+	if result.Action != "" || len(result.Set) > 0 {
+		var before interface{}
+		if runner.audit {
+			before = exec.snapshot()
 		}
-		_, err := actionFunc(goja.Undefined())
-		if err != nil {
-			return fmt.Errorf("error running action: %w", err)
+		start := time.Now()
+		if result.Action != "" {
+			action, ok := exec.functions[result.Name]
+			if !ok {
+				return &FunctionNotFoundError{Kind: "action", Name: result.Name}
+			}
+			runner.decisionCallback("Running action: [%s] %s", action.name, result.Description)
+			_, err := action.fn(goja.Undefined(), args...)
+			if err != nil {
+				return fmt.Errorf("error running action: %w", exec.scriptError(err, result))
+			}
+		}
+		if len(result.Set) > 0 {
+			set, ok := exec.functions[result.Name+setSuffix]
+			if !ok {
+				return &FunctionNotFoundError{Kind: "set", Name: result.Name}
+			}
+			runner.decisionCallback("Setting context fields: [%s]", result.Name)
+			_, err := set.fn(goja.Undefined(), args...)
+			if err != nil {
+				return fmt.Errorf("error setting context fields: %w", exec.scriptError(err, result))
+			}
+		}
+		duration := time.Since(start)
+
+		var changes []ContextChange
+		if runner.audit {
+			changes = exec.changes(result.Name, before)
 		}
+		exec.trace.record(exec.event(TraceAction, TraceEvent{Decision: result.Name, Duration: duration, Changes: changes}))
 	}
 
+	return nil
+}
+
+// proceed moves on to the next condition of the decision, if any
+func (exec *execution[Context]) proceed(result *Decision) (*Condition, error) {
+	runner := exec.runner
+
 	if result.Next != "" {
-		nextCondition, err := findConditionByName(rules, result.Next)
+		nextCondition, err := findConditionByName(exec.rules, result.Next)
 		if err != nil {
-			return fmt.Errorf("unexpected error: condition '%s' not found", result.Next)
+			return nil, fmt.Errorf("unexpected error: %w", &ConditionNotFoundError{Condition: result.Next})
 		}
 		runner.decisionCallback("Moving to next condition:[%s]", nextCondition.Name)
-		err = runner.runCondition(vm, rules, nextCondition)
-		if err != nil {
-			return fmt.Errorf("error while evaluating condition '%s': %w", result.Next, err)
-		}
+		exec.trace.record(exec.event(TraceNext, TraceEvent{Decision: result.Name, Next: nextCondition.Name}))
+		return nextCondition, nil
 	}
 
 	if result.Terminate {
 		runner.decisionCallback("Terminating")
+	}
+	exec.trace.record(exec.event(TraceTerminate, TraceEvent{Decision: result.Name}))
+
+	return nil, nil
+}
+
+// call starts the called rule set of the decision at its default condition
+func (exec *execution[Context]) call(result *Decision) (*Condition, error) {
+	entry, ok := exec.rules.entry(result.Call)
+	if !ok {
+		return nil, fmt.Errorf("called rule set '%s' not found", result.Call)
+	}
+	condition, err := findConditionByName(exec.rules, entry)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error: %w", &ConditionNotFoundError{Condition: entry})
+	}
+
+	exec.runner.decisionCallback("Calling rule set:[%s]", result.Call)
+	exec.stack = append(exec.stack, callFrame{ruleSet: result.Call, caller: exec.current, decision: result})
+	exec.trace.record(exec.event(TraceCall, TraceEvent{Decision: result.Name, Call: result.Call, Next: condition.Name}))
+	return condition, nil
+}
+
+// returnFromCall finishes the innermost rule set call and moves on with the decision that made it
+func (exec *execution[Context]) returnFromCall() (*Condition, error) {
+	frame := exec.stack[len(exec.stack)-1]
+	exec.stack = exec.stack[:len(exec.stack)-1]
+
+	exec.runner.decisionCallback("Returning from rule set:[%s]", frame.ruleSet)
+	exec.current = frame.caller
+	exec.trace.record(exec.event(TraceReturn, TraceEvent{Decision: frame.decision.Name, Call: frame.ruleSet}))
+	return exec.proceed(frame.decision)
+}
+
+// scriptError wraps the error of the action or set function of a decision taken by the current condition
+func (exec *execution[Context]) scriptError(err error, decision *Decision) *ScriptError {
+	scriptErr := newScriptError(err, "", "", decision.Name)
+	if exec.current != nil {
+		scriptErr = newScriptError(err, exec.current.File, exec.current.Name, decision.Name)
+	}
+	return exec.rules.locateError(scriptErr, decision.Name, decision.source, 0, decision.Position)
+}
+
+// callStack returns the rule set calls in progress, innermost last
+func (exec *execution[Context]) callStack() []CallFrame {
+	if len(exec.stack) == 0 {
+		return nil
+	}
+	frames := make([]CallFrame, len(exec.stack))
+	for i, frame := range exec.stack {
+		frames[i] = CallFrame{RuleSet: frame.ruleSet, Condition: frame.caller.Name}
+	}
+	return frames
+}
+
+// event completes a trace event with its type and the condition being evaluated
+func (exec *execution[Context]) event(eventType TraceEventType, event TraceEvent) TraceEvent {
+	event.Type = eventType
+	if exec.current != nil {
+		event.RuleSet = exec.current.RuleSet
+		event.File = exec.current.File
+		event.Line, event.Column = exec.current.Position.Line, exec.current.Position.Column
+		event.Condition = exec.current.Name
+	}
+	if exec.trace != nil {
+		event.CallStack = exec.callStack()
+	}
+	return event
+}
+
+// errorEvent returns the trace event of an error, located at the failing javascript if known
+func (exec *execution[Context]) errorEvent(err error) TraceEvent {
+	event := exec.event(TraceError, TraceEvent{Error: err.Error()})
+	var scriptErr *ScriptError
+	if errors.As(err, &scriptErr) && scriptErr.Position.IsValid() {
+		event.File = ifEmpty(scriptErr.Position.File, event.File)
+		event.Line, event.Column = scriptErr.Position.Line, scriptErr.Position.Column
+	}
+	return event
+}
+
+// step accounts for the evaluation of a condition against the run's context and the runner's step and visit budgets
+func (exec *execution[Context]) step(condition *Condition) error {
+	if err := exec.ctx.Err(); err != nil {
+		return err
+	}
+
+	maxSteps, maxVisits := exec.runner.maxSteps, exec.runner.maxVisits
+	if maxSteps <= 0 && maxVisits <= 0 {
 		return nil
 	}
 
+	exec.steps++
+	exec.visits[condition.Name]++
+	exec.path = append(exec.path, condition.Name)
+
+	if maxSteps > 0 && exec.steps > maxSteps {
+		return &CycleError{Limit: maxSteps, Condition: condition.Name, Path: exec.cyclePath()}
+	}
+
+	if maxVisits > 0 && exec.visits[condition.Name] > maxVisits {
+		return &CycleError{Limit: maxVisits, PerCondition: true, Condition: condition.Name, Path: exec.cyclePath()}
+	}
+
 	return nil
 }
 
+// cyclePath returns the conditions between the last two evaluations of the current condition
+func (exec *execution[Context]) cyclePath() []string {
+	last := len(exec.path) - 1
+	for i := last - 1; i >= 0; i-- {
+		if exec.path[i] == exec.path[last] {
+			return append([]string{}, exec.path[i:]...)
+		}
+	}
+	return append([]string{}, exec.path...)
+}
+
 func findConditionByName(rule *Rules, name string) (*Condition, error) {
 	if condition, ok := rule.Conditions[name]; ok {
 		return &condition, nil
 	}
 
-	return nil, fmt.Errorf("Condition not found: %s", name)
+	return nil, &ConditionNotFoundError{Condition: name}
 }
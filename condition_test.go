@@ -89,7 +89,6 @@ next: "nextCondition"
 func TestRunCondition_CheckFunctionNotFound(t *testing.T) {
 	vm := goja.New()
 	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
 		decisionCallback: func(format string, args ...interface{}) {},
 	}
 	
@@ -104,7 +103,7 @@ func TestRunCondition_CheckFunctionNotFound(t *testing.T) {
 	}
 	
 	condition := rules.Conditions["test"]
-	err := runner.runCondition(vm, rules, &condition)
+	err := runner.newExecution(vm, registry{}, rules).run(&condition)
 	
 	if err == nil {
 		t.Fatal("Expected error for missing check function")
@@ -117,15 +116,15 @@ func TestRunCondition_CheckFunctionNotFound(t *testing.T) {
 func TestRunCondition_CheckFunctionExecutionError(t *testing.T) {
 	vm := goja.New()
 	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
 		decisionCallback: func(format string, args ...interface{}) {},
 	}
 	
 	// Add a function that throws an error
-	vm.Set("testCheck", func() error {
-		return errors.New("check error")
+	functions := testFunctions(vm, map[string]interface{}{
+		"test": func() error {
+			return errors.New("check error")
+		},
 	})
-	runner.functionNames["test"] = "testCheck"
 	
 	rules := &Rules{
 		Conditions: map[string]Condition{
@@ -138,7 +137,7 @@ func TestRunCondition_CheckFunctionExecutionError(t *testing.T) {
 	}
 	
 	condition := rules.Conditions["test"]
-	err := runner.runCondition(vm, rules, &condition)
+	err := runner.newExecution(vm, functions, rules).run(&condition)
 	
 	if err == nil {
 		t.Fatal("Expected error from check function")
@@ -153,15 +152,14 @@ func TestRunCondition_TrueBranchExecution(t *testing.T) {
 	actionExecuted := false
 	
 	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
 		decisionCallback: func(format string, args ...interface{}) {},
 	}
 	
 	// Add functions
-	vm.Set("testCheck", func() bool { return true })
-	vm.Set("trueAction", func() { actionExecuted = true })
-	runner.functionNames["test"] = "testCheck"
-	runner.functionNames["test_true"] = "trueAction"
+	functions := testFunctions(vm, map[string]interface{}{
+		"test":      func() bool { return true },
+		"test_true": func() { actionExecuted = true },
+	})
 	
 	rules := &Rules{
 		Conditions: map[string]Condition{
@@ -180,7 +178,7 @@ func TestRunCondition_TrueBranchExecution(t *testing.T) {
 	}
 	
 	condition := rules.Conditions["test"]
-	err := runner.runCondition(vm, rules, &condition)
+	err := runner.newExecution(vm, functions, rules).run(&condition)
 	
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -195,15 +193,14 @@ func TestRunCondition_FalseBranchExecution(t *testing.T) {
 	actionExecuted := false
 	
 	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
 		decisionCallback: func(format string, args ...interface{}) {},
 	}
 	
 	// Add functions
-	vm.Set("testCheck", func() bool { return false })
-	vm.Set("falseAction", func() { actionExecuted = true })
-	runner.functionNames["test"] = "testCheck"
-	runner.functionNames["test_false"] = "falseAction"
+	functions := testFunctions(vm, map[string]interface{}{
+		"test":       func() bool { return false },
+		"test_false": func() { actionExecuted = true },
+	})
 	
 	rules := &Rules{
 		Conditions: map[string]Condition{
@@ -222,7 +219,7 @@ func TestRunCondition_FalseBranchExecution(t *testing.T) {
 	}
 	
 	condition := rules.Conditions["test"]
-	err := runner.runCondition(vm, rules, &condition)
+	err := runner.newExecution(vm, functions, rules).run(&condition)
 	
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -235,13 +232,13 @@ func TestRunCondition_FalseBranchExecution(t *testing.T) {
 func TestRunCondition_NullDecisionHandling(t *testing.T) {
 	vm := goja.New()
 	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
 		decisionCallback: func(format string, args ...interface{}) {},
 	}
 	
 	// Add function
-	vm.Set("testCheck", func() bool { return true })
-	runner.functionNames["test"] = "testCheck"
+	functions := testFunctions(vm, map[string]interface{}{
+		"test": func() bool { return true },
+	})
 	
 	rules := &Rules{
 		Conditions: map[string]Condition{
@@ -255,7 +252,7 @@ func TestRunCondition_NullDecisionHandling(t *testing.T) {
 	}
 	
 	condition := rules.Conditions["test"]
-	err := runner.runCondition(vm, rules, &condition)
+	err := runner.newExecution(vm, functions, rules).run(&condition)
 	
 	if err != nil {
 		t.Fatalf("Expected no error for null decision, got: %v", err)
@@ -265,7 +262,6 @@ func TestRunCondition_NullDecisionHandling(t *testing.T) {
 func TestRunAction_ActionFunctionNotFound(t *testing.T) {
 	vm := goja.New()
 	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
 		decisionCallback: func(format string, args ...interface{}) {},
 	}
 	
@@ -275,7 +271,7 @@ func TestRunAction_ActionFunctionNotFound(t *testing.T) {
 		Action: "nonExistentAction()",
 	}
 	
-	err := runner.runAction(vm, rules, decision)
+	_, err := runner.newExecution(vm, registry{}, rules).decide(decision)
 	
 	if err == nil {
 		t.Fatal("Expected error for missing action function")
@@ -288,15 +284,15 @@ func TestRunAction_ActionFunctionNotFound(t *testing.T) {
 func TestRunAction_ActionFunctionExecutionError(t *testing.T) {
 	vm := goja.New()
 	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
 		decisionCallback: func(format string, args ...interface{}) {},
 	}
 	
 	// Add a function that throws an error
-	vm.Set("errorAction", func() error {
-		return errors.New("action error")
+	functions := testFunctions(vm, map[string]interface{}{
+		"testAction": func() error {
+			return errors.New("action error")
+		},
 	})
-	runner.functionNames["testAction"] = "errorAction"
 	
 	rules := &Rules{}
 	decision := &Decision{
@@ -304,7 +300,7 @@ func TestRunAction_ActionFunctionExecutionError(t *testing.T) {
 		Action: "errorAction()",
 	}
 	
-	err := runner.runAction(vm, rules, decision)
+	_, err := runner.newExecution(vm, functions, rules).decide(decision)
 	
 	if err == nil {
 		t.Fatal("Expected error from action function")
@@ -317,7 +313,6 @@ func TestRunAction_ActionFunctionExecutionError(t *testing.T) {
 func TestRunAction_NextConditionNotFound(t *testing.T) {
 	vm := goja.New()
 	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
 		decisionCallback: func(format string, args ...interface{}) {},
 	}
 	
@@ -329,7 +324,7 @@ func TestRunAction_NextConditionNotFound(t *testing.T) {
 		Next: "nonExistentCondition",
 	}
 	
-	err := runner.runAction(vm, rules, decision)
+	_, err := runner.newExecution(vm, registry{}, rules).decide(decision)
 	
 	if err == nil {
 		t.Fatal("Expected error for missing next condition")
@@ -344,7 +339,6 @@ func TestRunAction_TerminateFlagBehavior(t *testing.T) {
 	terminated := false
 	
 	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
 		decisionCallback: func(format string, args ...interface{}) {
 			if format == "Terminating" {
 				terminated = true
@@ -358,7 +352,7 @@ func TestRunAction_TerminateFlagBehavior(t *testing.T) {
 		Terminate: true,
 	}
 	
-	err := runner.runAction(vm, rules, decision)
+	_, err := runner.newExecution(vm, registry{}, rules).decide(decision)
 	
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -404,11 +398,25 @@ func TestFindConditionByName_ConditionNotFound(t *testing.T) {
 	if condition != nil {
 		t.Error("Expected nil condition")
 	}
-	if err.Error() != "Condition not found: nonExistent" {
+	var notFound *ConditionNotFoundError
+	if !errors.As(err, &notFound) || notFound.Condition != "nonExistent" {
+		t.Errorf("Expected ConditionNotFoundError, got: %v", err)
+	}
+	if err.Error() != "condition 'nonExistent' not found" {
 		t.Errorf("Expected specific error message, got: %v", err)
 	}
 }
 
+// Helper function to build a registry of check and action functions from go functions
+func testFunctions(vm *goja.Runtime, functions map[string]interface{}) registry {
+	result := registry{}
+	for key, f := range functions {
+		fn, _ := goja.AssertFunction(vm.ToValue(f))
+		result[key] = registeredFunction{name: key, fn: fn}
+	}
+	return result
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && len(substr) > 0 && findInString(s, substr))
@@ -0,0 +1,351 @@
+package yabre
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// jsonFieldNameMapper exposes struct fields to javascript under the name of their json tag, and under their Go name
+// when they have no json tag; fields tagged `json:"-"` are hidden. Methods keep their Go name.
+type jsonFieldNameMapper struct{}
+
+func (jsonFieldNameMapper) FieldName(_ reflect.Type, f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+func (jsonFieldNameMapper) MethodName(_ reflect.Type, m reflect.Method) string {
+	return m.Name
+}
+
+// setContext sets the context of a run in the vm. Struct contexts, and pointers to structs, are exposed as a structContext,
+// so writes of the rules are checked against the fields of the struct.
+func (rr *RulesRunner[Context]) setContext(vm *goja.Runtime, rulesContext *Context) {
+	value := reflect.ValueOf(rulesContext).Elem()
+	target := value
+	if target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	if target.Kind() != reflect.Struct || len(structFields(target.Type(), rr.jsonFieldNames)) == 0 {
+		vm.Set("context", *rulesContext)
+		return
+	}
+
+	state := &contextState{contextType: reflect.TypeOf(rulesContext).Elem().String(), jsonFieldNames: rr.jsonFieldNames}
+	if value.Kind() == reflect.Struct {
+		// the rules modify a copy, which is only written back once the run succeeded
+		state.context = reflect.New(value.Type()).Elem()
+		state.context.Set(value)
+		target = state.context
+	} else {
+		state.context = value
+	}
+	vm.Set("context", newStructContext(vm, target, "", state))
+}
+
+// exportContext writes the javascript context of a run back into rulesContext.
+// The context is the Go value set by the run, modified in place by the rules, unless the rules assigned another value to it:
+// that value is then converted into the Context type, and a *ContextError is returned when it can't be converted
+// or has properties the Context struct has no field for.
+func (rr *RulesRunner[Context]) exportContext(vm *goja.Runtime, rulesContext *Context) error {
+	var result Context
+	contextType := reflect.TypeOf(rulesContext).Elem().String()
+
+	value := vm.Get("context")
+	if value == nil {
+		value = goja.Undefined()
+	}
+	if goja.IsUndefined(value) || goja.IsNull(value) {
+		return &ContextError{Type: contextType, Reason: "context is " + value.String()}
+	}
+
+	if context, ok := value.Export().(*structContext); ok {
+		if context.state.err != nil {
+			return context.state.err
+		}
+		if exported, ok := context.state.context.Interface().(Context); ok {
+			*rulesContext = exported
+			return nil
+		}
+	}
+	if exported, ok := value.Export().(Context); ok {
+		*rulesContext = exported
+		return nil
+	}
+
+	if err := vm.ExportTo(value, &result); err != nil {
+		return &ContextError{Type: contextType, Reason: "context can't be converted", Err: err}
+	}
+	if object, ok := value.(*goja.Object); ok {
+		if unknown := unknownFields(reflect.TypeOf(result), object.Keys(), rr.jsonFieldNames); len(unknown) > 0 {
+			return &ContextError{Type: contextType, Reason: "context has no field for " + strings.Join(unknown, ", ")}
+		}
+	}
+
+	*rulesContext = result
+	return nil
+}
+
+// structFieldsCache holds the fields of struct types by structFieldsKey, as contexts expose the same types on every run
+var structFieldsCache sync.Map
+
+type structFieldsKey struct {
+	t              reflect.Type
+	jsonFieldNames bool
+}
+
+// structFields maps the names struct fields are exposed to javascript as to their index. The map must not be modified.
+func structFields(t reflect.Type, jsonFieldNames bool) map[string][]int {
+	key := structFieldsKey{t: t, jsonFieldNames: jsonFieldNames}
+	if fields, ok := structFieldsCache.Load(key); ok {
+		return fields.(map[string][]int)
+	}
+
+	fields := map[string][]int{}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name := field.Name
+		if jsonFieldNames {
+			name = jsonFieldNameMapper{}.FieldName(t, field)
+		}
+		// fields of embedded structs are shadowed by shallower fields of the same name
+		if index, exists := fields[name]; name != "" && (!exists || len(field.Index) < len(index)) {
+			fields[name] = field.Index
+		}
+	}
+	structFieldsCache.Store(key, fields)
+	return fields
+}
+
+// unknownFields returns the properties of a javascript object that no field of a struct type is exposed as
+func unknownFields(t reflect.Type, keys []string, jsonFieldNames bool) []string {
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := structFields(t, jsonFieldNames)
+	unknown := []string{}
+	for _, key := range keys {
+		if _, ok := fields[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// contextState is shared by the structContext objects of a run
+type contextState struct {
+	// Context type of the runner and context of the run, the copy modified by the rules for struct contexts
+	contextType string
+	context     reflect.Value
+	// fields are exposed under their json names
+	jsonFieldNames bool
+	// first write of the rules that failed
+	err *ContextError
+}
+
+// structContext exposes a struct of the context to javascript. Writing a property the struct has no field for,
+// or a value its field can't hold, throws a *ContextError and fails the run, instead of dropping or zeroing the value.
+type structContext struct {
+	vm *goja.Runtime
+	// addressable struct the fields are read from and written to
+	value  reflect.Value
+	fields map[string][]int
+	// path of the struct in the context, empty for the context itself
+	path  string
+	state *contextState
+	// objects exposing the struct and slice fields, which refer to the fields and stay valid until they are set
+	cache map[string]goja.Value
+}
+
+func newStructContext(vm *goja.Runtime, value reflect.Value, path string, state *contextState) *goja.Object {
+	return vm.NewDynamicObject(&structContext{vm: vm, value: value, fields: structFields(value.Type(), state.jsonFieldNames), path: path, state: state})
+}
+
+func (c *structContext) Get(key string) goja.Value {
+	index, ok := c.fields[key]
+	if !ok {
+		// methods of the struct
+		return c.vm.ToValue(c.value.Addr().Interface()).ToObject(c.vm).Get(key)
+	}
+	field, err := c.value.FieldByIndexErr(index)
+	if err != nil {
+		return goja.Undefined()
+	}
+
+	if cached, ok := c.cache[key]; ok {
+		return cached
+	}
+
+	var value goja.Value
+	switch {
+	case field.Kind() == reflect.Struct && len(structFields(field.Type(), c.state.jsonFieldNames)) > 0:
+		value = newStructContext(c.vm, field, joinPath(c.path, key), c.state)
+	case field.Kind() == reflect.Pointer && !field.IsNil() && field.Elem().Kind() == reflect.Struct &&
+		len(structFields(field.Elem().Type(), c.state.jsonFieldNames)) > 0:
+		value = newStructContext(c.vm, field.Elem(), joinPath(c.path, key), c.state)
+	case field.Kind() == reflect.Slice || field.Kind() == reflect.Array:
+		// slices are exposed through a pointer, so methods like push modify the field
+		value = c.vm.ToValue(field.Addr().Interface())
+	default:
+		return c.vm.ToValue(field.Interface())
+	}
+	if c.cache == nil {
+		c.cache = map[string]goja.Value{}
+	}
+	c.cache[key] = value
+	return value
+}
+
+func (c *structContext) Set(key string, val goja.Value) bool {
+	path := joinPath(c.path, key)
+	index, ok := c.fields[key]
+	if !ok {
+		c.fail(&ContextError{Type: c.state.contextType, Reason: "context has no field for " + path})
+	}
+	field, err := c.value.FieldByIndexErr(index)
+	if err != nil {
+		c.fail(&ContextError{Type: c.state.contextType, Reason: "field " + path + " can't be set", Err: err})
+	}
+	converted, contextErr := c.convert(val, field.Type(), path)
+	if contextErr != nil {
+		c.fail(contextErr)
+	}
+	field.Set(converted)
+	delete(c.cache, key)
+	return true
+}
+
+func (c *structContext) Has(key string) bool {
+	if _, ok := c.fields[key]; ok {
+		return true
+	}
+	return c.vm.ToValue(c.value.Addr().Interface()).ToObject(c.vm).Get(key) != nil
+}
+
+// Delete fails for fields, which can't be removed from a struct
+func (c *structContext) Delete(key string) bool {
+	_, ok := c.fields[key]
+	return !ok
+}
+
+func (c *structContext) Keys() []string {
+	keys := make([]string, 0, len(c.fields))
+	for key := range c.fields {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return lessIndex(c.fields[keys[i]], c.fields[keys[j]]) })
+	return keys
+}
+
+// lessIndex orders field indexes like the fields of their struct
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// fail records the first failed write of the run and throws it to the rules
+func (c *structContext) fail(err *ContextError) {
+	if c.state.err == nil {
+		c.state.err = err
+	}
+	panic(c.vm.NewGoError(err))
+}
+
+// convert converts a javascript value into a field type. Booleans, strings and numbers must have the type of the field,
+// so values the field can't hold aren't silently converted into its zero value.
+func (c *structContext) convert(val goja.Value, t reflect.Type, path string) (reflect.Value, *ContextError) {
+	mismatch := func(err error) *ContextError {
+		described := val.String()
+		if _, isString := val.Export().(string); isString {
+			described = strconv.Quote(described)
+		}
+		return &ContextError{Type: c.state.contextType, Reason: fmt.Sprintf("field %s of type %s can't be set to %s", path, t, described), Err: err}
+	}
+
+	converted := reflect.New(t).Elem()
+	exported := val.Export()
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := exported.(bool)
+		if !ok {
+			return converted, mismatch(nil)
+		}
+		converted.SetBool(b)
+	case reflect.String:
+		s, ok := exported.(string)
+		if !ok {
+			return converted, mismatch(nil)
+		}
+		converted.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := exported.(int64)
+		if f, isFloat := exported.(float64); isFloat && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+			n, ok = int64(f), true
+		}
+		if !ok || converted.OverflowInt(n) {
+			return converted, mismatch(nil)
+		}
+		converted.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := exported.(int64)
+		if f, isFloat := exported.(float64); isFloat && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+			n, ok = int64(f), true
+		}
+		if !ok || n < 0 || converted.OverflowUint(uint64(n)) {
+			return converted, mismatch(nil)
+		}
+		converted.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := exported.(type) {
+		case int64:
+			f = float64(n)
+		case float64:
+			f = n
+		default:
+			return converted, mismatch(nil)
+		}
+		if converted.OverflowFloat(f) {
+			return converted, mismatch(nil)
+		}
+		converted.SetFloat(f)
+	default:
+		if err := c.vm.ExportTo(val, converted.Addr().Interface()); err != nil {
+			return converted, mismatch(err)
+		}
+		structType := t
+		if structType.Kind() == reflect.Pointer {
+			structType = structType.Elem()
+		}
+		if object, ok := val.(*goja.Object); ok {
+			if unknown := unknownFields(structType, object.Keys(), c.state.jsonFieldNames); len(unknown) > 0 {
+				for i := range unknown {
+					unknown[i] = joinPath(path, unknown[i])
+				}
+				return converted, &ContextError{Type: c.state.contextType, Reason: "context has no field for " + strings.Join(unknown, ", ")}
+			}
+		}
+	}
+	return converted, nil
+}
@@ -0,0 +1,196 @@
+package yabre
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ParcelContext struct {
+	Weight   float64          `json:"weight"`
+	Result   string           `json:"result,omitempty"`
+	Dims     ParcelDimensions `json:"dims"`
+	Labels   []string         `json:"labels"`
+	Internal string           `json:"-"`
+	Carrier  string
+	Meta     map[string]string `json:"meta"`
+}
+
+type ParcelDimensions struct {
+	Length float64 `json:"length"`
+}
+
+func parcelRules(action string) []byte {
+	return []byte(`
+name: parcel
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      action: |
+        function() {
+          ` + action + `
+        }
+      terminate: true
+`)
+}
+
+func TestRunner_JSONFieldNames(t *testing.T) {
+	rulesContext := ParcelContext{Weight: 12, Dims: ParcelDimensions{Length: 30}, Labels: []string{}, Internal: "secret", Carrier: "post"}
+	runner, err := NewRulesRunnerFromYaml(parcelRules(`
+          context.result = context.weight > 10 && context.dims.length == 30 ? "heavy" : "light";
+          context.labels.push("fragile");
+          context.Carrier = typeof context.Internal === "undefined" ? context.Carrier + "-express" : "leaked";
+        `), &rulesContext, WithJSONFieldNames[ParcelContext]())
+	require.NoError(t, err)
+
+	result, err := runner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "heavy", result.Result)
+	assert.Equal(t, []string{"fragile"}, result.Labels)
+	assert.Equal(t, "post-express", result.Carrier)
+	assert.Equal(t, "secret", result.Internal)
+}
+
+func TestRunner_ReplacedContext(t *testing.T) {
+	rulesContext := ParcelContext{Weight: 1}
+	runner, err := NewRulesRunnerFromYaml(parcelRules(`context = {weight: context.weight * 2, result: "replaced", dims: {length: 5}, meta: {source: "js"}};`),
+		&rulesContext, WithJSONFieldNames[ParcelContext]())
+	require.NoError(t, err)
+
+	result, err := runner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ParcelContext{Weight: 2, Result: "replaced", Dims: ParcelDimensions{Length: 5}, Meta: map[string]string{"source": "js"}}, *result)
+
+	mapContext := map[string]interface{}{"weight": 1}
+	mapRunner, err := NewRulesRunnerFromYaml(parcelRules(`context = {weight: 3, added: true};`), &mapContext)
+	require.NoError(t, err)
+
+	mapResult, err := mapRunner.RunRules(&mapContext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"weight": int64(3), "added": true}, *mapResult)
+}
+
+func TestRunner_ContextError(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		expected string
+	}{
+		{
+			name:     "Unknown fields",
+			action:   `context = {weight: 2, volume: 3, color: "red"};`,
+			expected: "can't write context back into yabre.ParcelContext: context has no field for color, volume",
+		},
+		{
+			name:     "Go field names",
+			action:   `context = {Weight: 2};`,
+			expected: "can't write context back into yabre.ParcelContext: context has no field for Weight",
+		},
+		{
+			name:     "Null",
+			action:   `context = null;`,
+			expected: "can't write context back into yabre.ParcelContext: context is null",
+		},
+		{
+			name:     "Not convertible",
+			action:   `context = "parcel";`,
+			expected: "can't write context back into yabre.ParcelContext: context can't be converted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rulesContext := ParcelContext{Weight: 1}
+			runner, err := NewRulesRunnerFromYaml(parcelRules(tt.action), &rulesContext, WithJSONFieldNames[ParcelContext]())
+			require.NoError(t, err)
+
+			result, err := runner.RunRules(&rulesContext, nil)
+			var contextErr *ContextError
+			require.True(t, errors.As(err, &contextErr), err)
+			assert.Equal(t, "yabre.ParcelContext", contextErr.Type)
+			assert.Contains(t, err.Error(), tt.expected)
+			// the context is left as it was
+			assert.Equal(t, ParcelContext{Weight: 1}, *result)
+		})
+	}
+}
+
+type StockContext struct {
+	Weight   int
+	Name     string
+	Shipment *ParcelDimensions
+}
+
+func TestRunner_ContextWrites(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		expected string
+	}{
+		{
+			name:     "Unknown field",
+			action:   `context.Extra = 5;`,
+			expected: "can't write context back into yabre.StockContext: context has no field for Extra",
+		},
+		{
+			name:     "String into int field",
+			action:   `context.Weight = 'heavy';`,
+			expected: `can't write context back into yabre.StockContext: field Weight of type int can't be set to "heavy"`,
+		},
+		{
+			name:     "Fraction into int field",
+			action:   `context.Weight = 2.5;`,
+			expected: "can't write context back into yabre.StockContext: field Weight of type int can't be set to 2.5",
+		},
+		{
+			name:     "Nested unknown field",
+			action:   `context.Shipment.Width = 5;`,
+			expected: "can't write context back into yabre.StockContext: context has no field for Shipment.Width",
+		},
+		{
+			name:     "Object with unknown field",
+			action:   `context.Shipment = {Length: 1, Width: 2};`,
+			expected: "can't write context back into yabre.StockContext: context has no field for Shipment.Width",
+		},
+		{
+			name:     "Caught by the rules",
+			action:   `try { context.Name = 1; } catch (e) {} context.Weight = 3;`,
+			expected: "can't write context back into yabre.StockContext: field Name of type string can't be set to 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rulesContext := StockContext{Weight: 1, Shipment: &ParcelDimensions{Length: 2}}
+			runner, err := NewRulesRunnerFromYaml(parcelRules(tt.action), &rulesContext)
+			require.NoError(t, err)
+
+			result, err := runner.RunRules(&rulesContext, nil)
+			var contextErr *ContextError
+			require.True(t, errors.As(err, &contextErr), err)
+			assert.Equal(t, "yabre.StockContext", contextErr.Type)
+			assert.Contains(t, err.Error(), tt.expected)
+			assert.Equal(t, 1, result.Weight)
+		})
+	}
+
+	// valid writes are converted into the fields
+	rulesContext := StockContext{Weight: 1, Shipment: &ParcelDimensions{Length: 2}}
+	runner, err := NewRulesRunnerFromYaml(parcelRules(`context.Weight = context.Weight * 4; context.Name = "crate"; context.Shipment.Length = 2.5;`), &rulesContext)
+	require.NoError(t, err)
+	result, err := runner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StockContext{Weight: 4, Name: "crate", Shipment: &ParcelDimensions{Length: 2.5}}, *result)
+
+	// pointer contexts are checked too, and modified in place
+	pointerContext := &ParcelContext{Weight: 1}
+	pointerRunner, err := NewRulesRunnerFromYaml(parcelRules(`context.weight = 2; context.volume = 3;`), &pointerContext, WithJSONFieldNames[*ParcelContext]())
+	require.NoError(t, err)
+	_, err = pointerRunner.RunRules(&pointerContext, nil)
+	assert.ErrorContains(t, err, "can't write context back into *yabre.ParcelContext: context has no field for volume")
+	assert.Equal(t, 2.0, pointerContext.Weight)
+}
@@ -0,0 +1,403 @@
+package yabre
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dmnDefinitions is the subset of a DMN 1.x model the loader converts: decisions made of decision tables
+// and the information requirements between them
+type dmnDefinitions struct {
+	Name      string        `xml:"name,attr"`
+	ID        string        `xml:"id,attr"`
+	Decisions []dmnDecision `xml:"decision"`
+}
+
+type dmnDecision struct {
+	ID           string           `xml:"id,attr"`
+	Name         string           `xml:"name,attr"`
+	Requirements []dmnRequirement `xml:"informationRequirement"`
+	Table        *dmnTable        `xml:"decisionTable"`
+	Literal      *struct{}        `xml:"literalExpression"`
+}
+
+type dmnRequirement struct {
+	RequiredDecision *struct {
+		Href string `xml:"href,attr"`
+	} `xml:"requiredDecision"`
+}
+
+type dmnTable struct {
+	HitPolicy   string      `xml:"hitPolicy,attr"`
+	Aggregation string      `xml:"aggregation,attr"`
+	Inputs      []dmnInput  `xml:"input"`
+	Outputs     []dmnOutput `xml:"output"`
+	Rules       []dmnRule   `xml:"rule"`
+}
+
+type dmnInput struct {
+	Label      string `xml:"label,attr"`
+	Expression string `xml:"inputExpression>text"`
+}
+
+type dmnOutput struct {
+	Name  string `xml:"name,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type dmnRule struct {
+	InputEntries  []string `xml:"inputEntry>text"`
+	OutputEntries []string `xml:"outputEntry>text"`
+}
+
+var dmnHitPolicies = map[string]HitPolicy{
+	"":           HitUnique,
+	"UNIQUE":     HitUnique,
+	"FIRST":      HitFirst,
+	"ANY":        HitAny,
+	"COLLECT":    HitCollect,
+	"RULE ORDER": HitCollect,
+}
+
+// LoadDMN converts the decision tables of a DMN model and their decision requirements graph into rules.
+// Every decision becomes a decision table and a condition evaluating it; conditions run in the order of
+// the decision requirements, required decisions first, starting from the default condition.
+// Input expressions and output names are context fields, so decisions read the outputs of the decisions they require.
+//
+// Only the FEEL constructs that map to decision tables are supported: names and dotted paths, string, number
+// and boolean literals, comparisons, ranges, lists and `not(...)` in input entries and literals or names in
+// output entries. Anything else, including literal expression decisions, the PRIORITY and OUTPUT ORDER
+// hit policies and aggregations, is reported as an error naming the decision and rule.
+func LoadDMN(data []byte) (*Rules, error) {
+	var definitions dmnDefinitions
+	if err := xml.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("error parsing DMN: %w", err)
+	}
+
+	rules := &Rules{
+		Name:       ifEmpty(definitions.Name, definitions.ID),
+		Conditions: map[string]Condition{},
+		Tables:     map[string]*DecisionTable{},
+	}
+	if rules.Name == "" {
+		return nil, fmt.Errorf("DMN definitions have no name")
+	}
+
+	decisions, err := sortDecisions(definitions.Decisions)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for i, decision := range decisions {
+		name := dmnConditionName(decision.Name)
+		if names[name] {
+			return nil, fmt.Errorf("decision '%s': name %s is used by another decision", decision.Name, name)
+		}
+		names[name] = true
+
+		table, err := convertDecision(name, decision)
+		if err != nil {
+			return nil, fmt.Errorf("decision '%s': %w", decision.Name, err)
+		}
+		rules.Tables[name] = table
+
+		// a decision without matching rows doesn't stop the decisions requiring it
+		next := &Decision{Terminate: true}
+		if i < len(decisions)-1 {
+			next = &Decision{Next: dmnConditionName(decisions[i+1].Name)}
+		}
+		condition := Condition{
+			Name:        name,
+			Description: decision.Name,
+			Default:     i == 0,
+			Table:       name,
+			True:        next,
+			False:       &Decision{Next: next.Next, Terminate: next.Terminate},
+		}
+		condition.nameDecisions()
+		rules.Conditions[name] = condition
+		if condition.Default {
+			rules.DefaultCondition = &condition
+		}
+	}
+
+	rules.Hash = contentHash(data)
+	return rules, nil
+}
+
+// sortDecisions orders the decisions so that required decisions come first, keeping the document order otherwise
+func sortDecisions(decisions []dmnDecision) ([]dmnDecision, error) {
+	byID := map[string]dmnDecision{}
+	for _, decision := range decisions {
+		byID[decision.ID] = decision
+	}
+
+	sorted := []dmnDecision{}
+	state := map[string]int{} // 1: visiting, 2: visited
+	var visit func(decision dmnDecision) error
+	visit = func(decision dmnDecision) error {
+		switch state[decision.ID] {
+		case 1:
+			return fmt.Errorf("decision '%s': circular decision requirements", decision.Name)
+		case 2:
+			return nil
+		}
+		state[decision.ID] = 1
+		for _, requirement := range decision.Requirements {
+			if requirement.RequiredDecision == nil {
+				continue
+			}
+			id := strings.TrimPrefix(requirement.RequiredDecision.Href, "#")
+			required, ok := byID[id]
+			if !ok {
+				return fmt.Errorf("decision '%s': required decision %s not found", decision.Name, id)
+			}
+			if err := visit(required); err != nil {
+				return err
+			}
+		}
+		state[decision.ID] = 2
+		sorted = append(sorted, decision)
+		return nil
+	}
+
+	for _, decision := range decisions {
+		if err := visit(decision); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// convertDecision converts the decision table of a decision
+func convertDecision(name string, decision dmnDecision) (*DecisionTable, error) {
+	if decision.Table == nil {
+		if decision.Literal != nil {
+			return nil, fmt.Errorf("literal expression decisions are not supported")
+		}
+		return nil, fmt.Errorf("decision has no decision table")
+	}
+	dmn := decision.Table
+
+	if dmn.Aggregation != "" {
+		return nil, fmt.Errorf("aggregation %s is not supported", dmn.Aggregation)
+	}
+	hitPolicy, ok := dmnHitPolicies[dmn.HitPolicy]
+	if !ok {
+		return nil, fmt.Errorf("hit policy %s is not supported", dmn.HitPolicy)
+	}
+
+	table := &DecisionTable{Name: name, Description: decision.Name, HitPolicy: hitPolicy}
+
+	inputNames := map[string]bool{}
+	for i, input := range dmn.Inputs {
+		expr, err := feelName(input.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i+1, err)
+		}
+		inputName := dmnConditionName(ifEmpty(input.Label, input.Expression))
+		if !identifierRegex.MatchString(inputName) || inputNames[inputName] || inputName == "context" {
+			inputName = fmt.Sprintf("input%d", i+1)
+		}
+		inputNames[inputName] = true
+		table.Inputs = append(table.Inputs, TableInput{Name: inputName, Expr: expr})
+	}
+
+	for i, output := range dmn.Outputs {
+		field := output.Name
+		if field == "" && len(dmn.Outputs) == 1 {
+			field = decision.Name
+		}
+		if !fieldPathRegex.MatchString(field) {
+			return nil, fmt.Errorf("output %d: name '%s' should be a context field name", i+1, field)
+		}
+		table.Outputs = append(table.Outputs, field)
+	}
+
+	for i, rule := range dmn.Rules {
+		row := TableRow{}
+		for _, entry := range rule.InputEntries {
+			predicate, err := feelUnaryTests(entry)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i+1, err)
+			}
+			row.When = append(row.When, predicate)
+		}
+		for _, entry := range rule.OutputEntries {
+			value, err := feelOutput(entry)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i+1, err)
+			}
+			row.Then = append(row.Then, value)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	if _, err := tableFunction(table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+var (
+	feelNameRegex    = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+	feelNumberRegex  = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	feelRangeRegex   = regexp.MustCompile(`^([\[\(\]])\s*(.+?)\s*\.\.\s*(.+?)\s*([\]\)\[])$`)
+	feelCompareRegex = regexp.MustCompile(`^(<=|>=|<|>)\s*(.+)$`)
+	nonNameRegex     = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// dmnConditionName turns a DMN decision or input name into a condition name such as `customer_discount`
+func dmnConditionName(name string) string {
+	return strings.Trim(nonNameRegex.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// feelName converts a FEEL name or dotted path into a context field access
+func feelName(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if !feelNameRegex.MatchString(text) {
+		return "", fmt.Errorf("unsupported FEEL expression '%s': only names and dotted paths are supported", text)
+	}
+	return "context." + text, nil
+}
+
+// feelValue converts a FEEL literal or name into a javascript expression
+func feelValue(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case strings.HasPrefix(text, `"`):
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return "", fmt.Errorf("unsupported FEEL string %s", text)
+		}
+		return jsString(s), nil
+	case feelNumberRegex.MatchString(text), text == "true", text == "false", text == "null":
+		return text, nil
+	}
+	name, err := feelName(text)
+	if err != nil {
+		return "", fmt.Errorf("unsupported FEEL expression '%s': only literals and names are supported", text)
+	}
+	return name, nil
+}
+
+// feelUnaryTests converts the FEEL unary tests of an input entry into a decision table predicate
+func feelUnaryTests(text string) (interface{}, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || text == "-" {
+		return "-", nil
+	}
+
+	negate := false
+	if strings.HasPrefix(text, "not(") && strings.HasSuffix(text, ")") {
+		negate = true
+		text = strings.TrimSpace(text[len("not(") : len(text)-1])
+	}
+
+	tests := []string{}
+	for _, part := range splitFEELList(text) {
+		test, err := feelUnaryTest(part)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, test)
+	}
+
+	// keep single comparisons readable in the table
+	if !negate && len(tests) == 1 && feelCompareRegex.MatchString(text) {
+		return tests[0][len("value "):], nil
+	}
+
+	test := strings.Join(tests, " || ")
+	if negate {
+		return "= !(" + test + ")", nil
+	}
+	return "= " + test, nil
+}
+
+// feelUnaryTest converts a single FEEL unary test into a javascript test of `value`
+func feelUnaryTest(text string) (string, error) {
+	if match := feelCompareRegex.FindStringSubmatch(text); match != nil {
+		value, err := feelValue(match[2])
+		if err != nil {
+			return "", fmt.Errorf("unsupported FEEL unary test '%s': %w", text, err)
+		}
+		return fmt.Sprintf("value %s %s", match[1], value), nil
+	}
+
+	if match := feelRangeRegex.FindStringSubmatch(text); match != nil {
+		low, err := feelValue(match[2])
+		if err == nil {
+			var high string
+			if high, err = feelValue(match[3]); err == nil {
+				lowOp, highOp := ">", "<"
+				if match[1] == "[" {
+					lowOp = ">="
+				}
+				if match[4] == "]" {
+					highOp = "<="
+				}
+				return fmt.Sprintf("(value %s %s && value %s %s)", lowOp, low, highOp, high), nil
+			}
+		}
+		return "", fmt.Errorf("unsupported FEEL unary test '%s': %w", text, err)
+	}
+
+	value, err := feelValue(text)
+	if err != nil {
+		return "", fmt.Errorf("unsupported FEEL unary test '%s': %w", text, err)
+	}
+	return "value === " + value, nil
+}
+
+// feelOutput converts the FEEL expression of an output entry into a decision table value
+func feelOutput(text string) (interface{}, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "" || text == "null":
+		return nil, nil
+	case text == "true" || text == "false":
+		return text == "true", nil
+	case feelNumberRegex.MatchString(text):
+		return strconv.ParseFloat(text, 64)
+	case strings.HasPrefix(text, `"`):
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported FEEL string %s", text)
+		}
+		if strings.HasPrefix(s, "=") {
+			return "= " + jsString(s), nil
+		}
+		return s, nil
+	}
+	name, err := feelName(text)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported FEEL output '%s': only literals and names are supported", text)
+	}
+	return "= " + name, nil
+}
+
+// splitFEELList splits a comma separated list of unary tests, ignoring commas inside strings
+func splitFEELList(text string) []string {
+	parts := []string{}
+	inString := false
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			inString = !inString
+		case ',':
+			if !inString {
+				parts = append(parts, strings.TrimSpace(text[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(text[start:]))
+}
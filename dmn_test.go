@@ -0,0 +1,214 @@
+package yabre
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDMN(t *testing.T) {
+	data, err := os.ReadFile("test/dmn/customer_discount.dmn")
+	require.NoError(t, err)
+
+	rules, err := LoadDMN(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "customer_discount", rules.Name)
+	require.NotNil(t, rules.DefaultCondition)
+	// the required decision comes first
+	assert.Equal(t, "customer_level", rules.DefaultCondition.Name)
+	assert.Equal(t, "discount", rules.Conditions["customer_level"].True.Next)
+	assert.Equal(t, "discount", rules.Conditions["customer_level"].False.Next)
+	assert.True(t, rules.Conditions["discount"].True.Terminate)
+
+	level := rules.Tables["customer_level"]
+	require.NotNil(t, level)
+	assert.Equal(t, HitUnique, level.HitPolicy)
+	assert.Equal(t, []TableInput{{Name: "order_total", Expr: "context.order.total"}}, level.Inputs)
+	assert.Equal(t, []string{"level"}, level.Outputs)
+	assert.Equal(t, []interface{}{"< 100"}, level.Rows[0].When)
+	assert.Equal(t, []interface{}{"= (value >= 100 && value < 1000)"}, level.Rows[1].When)
+
+	discount := rules.Tables["discount"]
+	assert.Equal(t, HitFirst, discount.HitPolicy)
+	assert.Equal(t, []interface{}{`= value === "business"`, `= value === "gold" || value === "platinum"`}, discount.Rows[0].When)
+	assert.Equal(t, []interface{}{0.15}, discount.Rows[0].Then)
+	assert.Equal(t, []interface{}{`= !(value === "business")`, `= value === "gold"`}, discount.Rows[2].When)
+
+	assert.NoError(t, rules.Validate())
+}
+
+func TestRunner_DMN(t *testing.T) {
+	data, err := os.ReadFile("test/dmn/customer_discount.dmn")
+	require.NoError(t, err)
+
+	tests := []struct {
+		customerType string
+		total        float64
+		level        string
+		discount     interface{}
+	}{
+		{"business", 2000, "platinum", 0.15},
+		{"business", 50, "standard", 0.1},
+		{"private", 500, "gold", 0.05},
+		{"private", 50, "standard", nil},
+	}
+
+	for _, tt := range tests {
+		rulesContext := map[string]interface{}{
+			"customerType": tt.customerType,
+			"order":        map[string]interface{}{"total": tt.total},
+		}
+		runner, err := NewRulesRunnerFromDMN(data, &rulesContext)
+		require.NoError(t, err)
+
+		result, err := runner.RunRules(&rulesContext, nil)
+		require.NoError(t, err)
+		assert.Equal(t, tt.level, (*result)["level"], tt)
+		assert.Equal(t, tt.discount, (*result)["discount"], tt)
+	}
+}
+
+func TestRunner_DMNAnyHitPolicy(t *testing.T) {
+	data := []byte(`<definitions xmlns="https://www.omg.org/spec/DMN/20191111/MODEL/" name="shipping">
+  <decision id="shipping" name="Shipping">
+    <decisionTable hitPolicy="ANY">
+      <input><inputExpression><text>country</text></inputExpression></input>
+      <input><inputExpression><text>total</text></inputExpression></input>
+      <output name="shipping"/>
+      <rule>
+        <inputEntry><text>"DE","AT"</text></inputEntry>
+        <inputEntry><text>-</text></inputEntry>
+        <outputEntry><text>"standard"</text></outputEntry>
+      </rule>
+      <rule>
+        <inputEntry><text>-</text></inputEntry>
+        <inputEntry><text>&lt; 50</text></inputEntry>
+        <outputEntry><text>"standard"</text></outputEntry>
+      </rule>
+      <rule>
+        <inputEntry><text>-</text></inputEntry>
+        <inputEntry><text>&gt;= 1000</text></inputEntry>
+        <outputEntry><text>"express"</text></outputEntry>
+      </rule>
+    </decisionTable>
+  </decision>
+</definitions>`)
+
+	tests := []struct {
+		country  string
+		total    float64
+		shipping string
+		err      string
+	}{
+		// all matching rows have the same output
+		{country: "DE", total: 20, shipping: "standard"},
+		{country: "US", total: 2000, shipping: "express"},
+		{country: "AT", total: 2000, err: "table shipping has any hit policy but matching rows have different outputs: 1, 3"},
+	}
+
+	for _, tt := range tests {
+		rulesContext := map[string]interface{}{"country": tt.country, "total": tt.total}
+		runner, err := NewRulesRunnerFromDMN(data, &rulesContext)
+		require.NoError(t, err)
+		assert.Equal(t, HitAny, runner.Rules.Tables["shipping"].HitPolicy)
+
+		result, err := runner.RunRules(&rulesContext, nil)
+		if tt.err != "" {
+			assert.ErrorContains(t, err, tt.err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.shipping, (*result)["shipping"], tt)
+	}
+}
+
+func TestRulesLibrary_DMN(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "./test/dmn"})
+	require.NoError(t, err)
+	assert.Equal(t, "customer_discount.dmn", rl.GetRuleNamesAndPaths()["customer_discount"])
+	require.NoError(t, rl.ValidateAll())
+
+	rulesContext := map[string]interface{}{
+		"customerType": "business",
+		"order":        map[string]interface{}{"total": 200},
+	}
+	runner, err := NewRulesRunnerFromLibrary(rl, "dmn-checkout", &rulesContext)
+	require.NoError(t, err)
+
+	result, err := runner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "gold", (*result)["level"])
+	assert.InDelta(t, 170.0, (*result)["price"], 0.001)
+}
+
+func TestLoadDMN_Errors(t *testing.T) {
+	decision := func(table string) string {
+		return `<definitions xmlns="https://www.omg.org/spec/DMN/20191111/MODEL/" name="errors">
+  <decision id="d" name="Decision">` + table + `</decision>
+</definitions>`
+	}
+
+	tests := []struct {
+		name     string
+		dmn      string
+		expected string
+	}{
+		{
+			name:     "Invalid XML",
+			dmn:      "<definitions",
+			expected: "error parsing DMN",
+		},
+		{
+			name:     "Literal expression",
+			dmn:      decision(`<literalExpression><text>a + b</text></literalExpression>`),
+			expected: "decision 'Decision': literal expression decisions are not supported",
+		},
+		{
+			name:     "Unsupported hit policy",
+			dmn:      decision(`<decisionTable hitPolicy="PRIORITY"><input><inputExpression><text>a</text></inputExpression></input><output name="b"/></decisionTable>`),
+			expected: "decision 'Decision': hit policy PRIORITY is not supported",
+		},
+		{
+			name:     "Aggregation",
+			dmn:      decision(`<decisionTable hitPolicy="COLLECT" aggregation="SUM"><output name="b"/></decisionTable>`),
+			expected: "decision 'Decision': aggregation SUM is not supported",
+		},
+		{
+			name:     "Input expression",
+			dmn:      decision(`<decisionTable><input><inputExpression><text>a + 1</text></inputExpression></input><output name="b"/></decisionTable>`),
+			expected: "decision 'Decision': input 1: unsupported FEEL expression 'a + 1'",
+		},
+		{
+			name: "Input entry",
+			dmn: decision(`<decisionTable><input><inputExpression><text>a</text></inputExpression></input><output name="b"/>
+<rule><inputEntry><text>"x"</text></inputEntry><outputEntry><text>1</text></outputEntry></rule>
+<rule><inputEntry><text>date("2020-01-01")</text></inputEntry><outputEntry><text>2</text></outputEntry></rule>
+</decisionTable>`),
+			expected: `decision 'Decision': rule 2: unsupported FEEL unary test 'date("2020-01-01")'`,
+		},
+		{
+			name: "Output entry",
+			dmn: decision(`<decisionTable><input><inputExpression><text>a</text></inputExpression></input><output name="b"/>
+<rule><inputEntry><text>-</text></inputEntry><outputEntry><text>a * 2</text></outputEntry></rule>
+</decisionTable>`),
+			expected: "decision 'Decision': rule 1: unsupported FEEL output 'a * 2'",
+		},
+		{
+			name: "Missing required decision",
+			dmn: decision(`<informationRequirement><requiredDecision href="#missing"/></informationRequirement>
+<decisionTable><output name="b"/></decisionTable>`),
+			expected: "decision 'Decision': required decision missing not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadDMN([]byte(tt.dmn))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
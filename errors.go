@@ -0,0 +1,199 @@
+package yabre
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+)
+
+// CycleError is returned when a run exceeds its step or per-condition visit budget,
+// which usually means that the rules contain a `next` cycle.
+type CycleError struct {
+	// Limit is the budget that has been exceeded
+	Limit int
+	// PerCondition is true when the per-condition visit budget has been exceeded
+	PerCondition bool
+	// Condition is the condition at which the budget has been exceeded
+	Condition string
+	// Path is the sequence of conditions leading back to Condition, e.g. [a b a]
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	if e.PerCondition {
+		return fmt.Sprintf("condition '%s' evaluated more than %d times, cycle: %s", e.Condition, e.Limit, strings.Join(e.Path, " -> "))
+	}
+	return fmt.Sprintf("more than %d steps evaluated at condition '%s', cycle: %s", e.Limit, e.Condition, strings.Join(e.Path, " -> "))
+}
+
+// DependencyCycleError is returned when rule sets of a library require each other
+type DependencyCycleError struct {
+	// Cycle is the chain of rule sets requiring each other, starting and ending with the same rule set, e.g. [a b a]
+	Cycle []string
+}
+
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// ConditionNotFoundError is returned when the execution moves to a condition that doesn't exist
+type ConditionNotFoundError struct {
+	Condition string
+}
+
+func (e *ConditionNotFoundError) Error() string {
+	return fmt.Sprintf("condition '%s' not found", e.Condition)
+}
+
+// FunctionNotFoundError is returned when the check, action or set function of a condition or decision isn't loaded
+type FunctionNotFoundError struct {
+	// Kind is `check`, `action` or `set`
+	Kind string
+	// Name is the name of the condition or decision the function belongs to
+	Name string
+}
+
+func (e *FunctionNotFoundError) Error() string {
+	return fmt.Sprintf("%s function not found: %s", e.Kind, e.Name)
+}
+
+// GoFunctionError is an error returned by a go function registered with WithGoFunction.
+// It is thrown into javascript, so it is found with errors.As in the *ScriptError of the function calling it.
+type GoFunctionError struct {
+	Function string
+	Err      error
+}
+
+func (e *GoFunctionError) Error() string {
+	return fmt.Sprintf("go function %s failed: %v", e.Function, e.Err)
+}
+
+func (e *GoFunctionError) Unwrap() error {
+	return e.Err
+}
+
+// ContextError is returned when the context modified by the rules can't be written back into the Context type of the runner,
+// or when the rules write a property a struct context has no field for or a value its field can't hold
+type ContextError struct {
+	// Type is the Context type of the runner
+	Type   string
+	Reason string
+	// Err is the conversion error, if any
+	Err error
+}
+
+func (e *ContextError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("can't write context back into %s: %s: %v", e.Type, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("can't write context back into %s: %s", e.Type, e.Reason)
+}
+
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
+
+// SignatureError is returned when a library verifying signatures reads a rule file without a valid detached signature
+type SignatureError struct {
+	// File is the path of the rule file
+	File string
+	Err  error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("invalid signature of rule file %s: %v", e.File, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// ScriptError is a javascript error raised while compiling or running the scripts or a check, action or set function
+type ScriptError struct {
+	// File is the rules file the javascript comes from, if known
+	File string
+	// Condition is the condition whose check or decision failed, if any
+	Condition string
+	// Decision is the decision whose action or set failed, if any
+	Decision string
+	// Source is the name of the javascript source the error is located in:
+	// the condition or decision name of a function, or the rules name for scripts
+	Source string
+	// Line and Column locate the error in Source, starting at 1; they are 0 if unknown
+	Line   int
+	Column int
+	// Position locates the error in the rules file, if known: at the failing javascript,
+	// or at the condition or decision when the javascript is generated by the engine
+	Position Position
+	// Stack is the javascript stack trace of errors thrown at run time
+	Stack string
+	// Err is the error raised by goja: a *goja.Exception, a *goja.CompilerSyntaxError or a parser error
+	Err error
+}
+
+func (e *ScriptError) Error() string {
+	var sb strings.Builder
+	if e.Position.IsValid() {
+		sb.WriteString(e.Position.String() + ": ")
+	} else if e.File != "" {
+		sb.WriteString(e.File + ": ")
+	}
+	if e.Condition != "" {
+		fmt.Fprintf(&sb, "condition '%s': ", e.Condition)
+	}
+	// the position in the rules file supersedes the position in the javascript source
+	if e.Line > 0 && !e.Position.IsValid() {
+		if e.Source != e.Condition {
+			fmt.Fprintf(&sb, "%s line %d:%d: ", e.Source, e.Line, e.Column)
+		} else {
+			fmt.Fprintf(&sb, "line %d:%d: ", e.Line, e.Column)
+		}
+	}
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// newScriptError wraps a goja error into a *ScriptError located at the innermost javascript frame or at the syntax error.
+// Functions are compiled as `(<function>)`, so columns on the first line of a function source are shifted back.
+func newScriptError(err error, file, condition, decision string) *ScriptError {
+	scriptErr := &ScriptError{File: file, Condition: condition, Decision: decision, Err: err}
+
+	var exception *goja.Exception
+	var syntaxErr *goja.CompilerSyntaxError
+	var parseErrs parser.ErrorList
+	var parseErr *parser.Error
+	switch {
+	case errors.As(err, &exception):
+		var stack bytes.Buffer
+		for _, frame := range exception.Stack() {
+			if position := frame.Position(); scriptErr.Line == 0 && position.Line > 0 {
+				scriptErr.Source, scriptErr.Line, scriptErr.Column = frame.SrcName(), position.Line, position.Column
+			}
+			stack.WriteString("at ")
+			frame.Write(&stack)
+			stack.WriteString("\n")
+		}
+		scriptErr.Stack = stack.String()
+	case errors.As(err, &syntaxErr) && syntaxErr.File != nil:
+		position := syntaxErr.File.Position(syntaxErr.Offset)
+		scriptErr.Source, scriptErr.Line, scriptErr.Column = position.Filename, position.Line, position.Column
+	case errors.As(err, &parseErrs) && len(parseErrs) > 0:
+		position := parseErrs[0].Position
+		scriptErr.Source, scriptErr.Line, scriptErr.Column = position.Filename, position.Line, position.Column
+	case errors.As(err, &parseErr):
+		scriptErr.Source, scriptErr.Line, scriptErr.Column = parseErr.Position.Filename, parseErr.Position.Line, parseErr.Position.Column
+	}
+
+	if scriptErr.Line == 1 && scriptErr.Source != "" && scriptErr.Source == ifEmpty(decision, condition) && scriptErr.Column > 1 {
+		scriptErr.Column--
+	}
+	return scriptErr
+}
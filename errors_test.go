@@ -0,0 +1,157 @@
+package yabre
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptError_Runtime(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "failing.yaml"), []byte(`
+name: failing
+conditions:
+  start:
+    default: true
+    check: |
+      function() {
+        var limit = 10;
+        return context.order.total > limit;
+      }
+    true:
+      terminate: true
+`), 0644))
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: tempDir})
+	require.NoError(t, err)
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(rl, "failing", &rulesContext)
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	var scriptErr *ScriptError
+	require.True(t, errors.As(err, &scriptErr), err)
+
+	assert.Equal(t, "failing.yaml", scriptErr.File)
+	assert.Equal(t, "start", scriptErr.Condition)
+	assert.Equal(t, "start", scriptErr.Source)
+	assert.Equal(t, 3, scriptErr.Line)
+	assert.Contains(t, scriptErr.Stack, "start:3:")
+
+	var exception *goja.Exception
+	assert.True(t, errors.As(err, &exception))
+	assert.Equal(t, Position{File: "failing.yaml", Line: 9, Column: 30}, scriptErr.Position)
+	assert.Contains(t, err.Error(), "failing.yaml:9:30: condition 'start': TypeError")
+}
+
+func TestScriptError_Action(t *testing.T) {
+	yamlRules := `
+name: failing-action
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      action: "function() { null.field = 1; }"
+      terminate: true
+`
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext)
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	var scriptErr *ScriptError
+	require.True(t, errors.As(err, &scriptErr), err)
+	assert.Equal(t, "start", scriptErr.Condition)
+	assert.Equal(t, "start_true", scriptErr.Decision)
+	assert.Equal(t, "start_true", scriptErr.Source)
+	assert.Equal(t, 1, scriptErr.Line)
+	assert.Equal(t, Position{Line: 8, Column: 34}, scriptErr.Position)
+	assert.Contains(t, err.Error(), "error running action: line 8:34: condition 'start': TypeError")
+}
+
+func TestScriptError_Syntax(t *testing.T) {
+	yamlRules := `
+name: syntax
+conditions:
+  start:
+    default: true
+    check: |
+      function() {
+        return 1 +;
+      }
+    true:
+      terminate: true
+`
+	rulesContext := map[string]interface{}{}
+	_, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext)
+
+	var scriptErr *ScriptError
+	require.True(t, errors.As(err, &scriptErr), err)
+	assert.Equal(t, "start", scriptErr.Condition)
+	assert.Equal(t, 2, scriptErr.Line)
+	assert.Contains(t, err.Error(), "error compiling condition function start")
+}
+
+func TestGoFunctionError(t *testing.T) {
+	yamlRules := `
+name: go-function
+conditions:
+  start:
+    default: true
+    check: "function() { return lookup('key'); }"
+    true:
+      terminate: true
+`
+	lookupErr := errors.New("lookup failed")
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext,
+		WithGoFunction[map[string]interface{}]("lookup", func(key string) (bool, error) {
+			return false, lookupErr
+		}))
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	var goErr *GoFunctionError
+	require.True(t, errors.As(err, &goErr), err)
+	assert.Equal(t, "lookup", goErr.Function)
+	assert.True(t, errors.Is(err, lookupErr))
+
+	var scriptErr *ScriptError
+	assert.True(t, errors.As(err, &scriptErr))
+}
+
+func TestConditionNotFoundError(t *testing.T) {
+	yamlRules := `
+name: dangling
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      next: missing
+`
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext)
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	var notFound *ConditionNotFoundError
+	require.True(t, errors.As(err, &notFound), err)
+	assert.Equal(t, "missing", notFound.Condition)
+}
+
+func TestFunctionNotFoundError(t *testing.T) {
+	runner := &RulesRunner[any]{decisionCallback: func(string, ...interface{}) {}}
+	_, err := runner.newExecution(goja.New(), registry{}, &Rules{}).decide(&Decision{Name: "start_true", Action: "function() {}"})
+
+	var notFound *FunctionNotFoundError
+	require.True(t, errors.As(err, &notFound), err)
+	assert.Equal(t, FunctionNotFoundError{Kind: "action", Name: "start_true"}, *notFound)
+}
@@ -0,0 +1,115 @@
+package yabre
+
+import (
+	"context"
+	"errors"
+)
+
+// RuleResult is the outcome of a single condition evaluated by Evaluate
+type RuleResult struct {
+	Condition string
+	// RuleSet and File identify the origin of the condition, if loaded from a library
+	RuleSet string
+	File    string
+	// Fired is true when the check is true or selected a case of a switch
+	Fired bool
+	// Value is the result of the check: a bool, or the value selecting the case of a switch
+	Value interface{}
+	// Decision is the name of the decision whose action and assignments ran, if any
+	Decision string
+	// Err is the error raised by the check or the decision, if any
+	Err error
+}
+
+// Evaluation lists the results of the conditions evaluated by Evaluate in order of evaluation
+type Evaluation struct {
+	Results []RuleResult
+}
+
+// Fired returns the results of the conditions that fired
+func (e *Evaluation) Fired() []RuleResult {
+	fired := []RuleResult{}
+	for _, result := range e.Results {
+		if result.Fired {
+			fired = append(fired, result)
+		}
+	}
+	return fired
+}
+
+// Errors returns the results of the conditions whose check or decision failed
+func (e *Evaluation) Errors() []RuleResult {
+	failed := []RuleResult{}
+	for _, result := range e.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Evaluate evaluates every condition of the rules independently instead of following a path from the default condition,
+// or only the conditions tagged with one of the groups, if any. Conditions are evaluated highest salience first, by name on ties.
+// The decision selected by a check runs its action and `set` assignments, but doesn't move to a next condition or call a rule set.
+//
+// A failing check or decision is recorded in the result of its condition and the evaluation goes on with the other conditions;
+// the returned error is only set when the evaluation was stopped by ctx or by the step budget of the runner.
+func (rr *RulesRunner[Context]) Evaluate(ctx context.Context, rulesContext *Context, groups ...string) (*Context, *Evaluation, error) {
+	evaluation := &Evaluation{Results: []RuleResult{}}
+	result, err := rr.execute(ctx, rulesContext, nil, func(exec *execution[Context]) error {
+		for _, condition := range rr.Rules.salienceOrder() {
+			if len(groups) > 0 && !condition.inGroups(groups) {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			ruleResult := exec.evaluateRule(condition)
+			evaluation.Results = append(evaluation.Results, ruleResult)
+
+			var cycleErr *CycleError
+			if errors.As(ruleResult.Err, &cycleErr) {
+				return ruleResult.Err
+			}
+		}
+		return nil
+	})
+	return result, evaluation, err
+}
+
+// evaluateRule evaluates a condition and runs the decision it selects without moving on
+func (exec *execution[Context]) evaluateRule(condition *Condition) RuleResult {
+	result := RuleResult{Condition: condition.Name, RuleSet: condition.RuleSet, File: condition.File}
+
+	decision, value, err := exec.evaluate(condition)
+	result.Value = value
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if condition.isSwitch() {
+		result.Fired = decision != nil
+	} else {
+		result.Fired = value == true
+	}
+	if decision == nil {
+		return result
+	}
+
+	result.Decision = decision.Name
+	result.Err = exec.act(decision)
+	return result
+}
+
+// inGroups reports whether the condition is tagged with any of the groups
+func (c *Condition) inGroups(groups []string) bool {
+	for _, group := range c.Groups {
+		for _, wanted := range groups {
+			if group == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package yabre
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const orderValidationRules = `
+name: order-validation
+conditions:
+  customer_required:
+    groups: [required]
+    expr: "!context.Customer"
+    true:
+      action: "function() { context.Errors.push('customer is required'); }"
+  items_required:
+    groups: [required]
+    expr: "!context.Items || context.Items.length === 0"
+    true:
+      action: "function() { context.Errors.push('items are required'); }"
+  total_positive:
+    groups: [amounts]
+    salience: 10
+    expr: context.Total <= 0
+    true:
+      action: "function() { context.Errors.push('total must be positive'); }"
+  currency:
+    groups: [amounts]
+    check: "function() { return context.Currency.Code; }"
+    cases:
+      EUR:
+        terminate: true
+      default:
+        action: "function() { context.Errors.push('unsupported currency'); }"
+`
+
+type OrderValidationContext struct {
+	Customer string
+	Items    []string
+	Total    float64
+	Currency map[string]interface{}
+	Errors   []string
+}
+
+func newOrderValidation(t *testing.T, rulesContext *OrderValidationContext) *RulesRunner[OrderValidationContext] {
+	runner, err := NewRulesRunnerFromYaml([]byte(orderValidationRules), rulesContext)
+	require.NoError(t, err)
+	return runner
+}
+
+func TestRunner_Evaluate(t *testing.T) {
+	rulesContext := OrderValidationContext{Errors: []string{}}
+	runner := newOrderValidation(t, &rulesContext)
+
+	result, evaluation, err := runner.Evaluate(context.Background(), &rulesContext)
+	require.NoError(t, err)
+
+	// all conditions are evaluated, highest salience first, despite the failing check
+	conditions := []string{}
+	for _, ruleResult := range evaluation.Results {
+		conditions = append(conditions, ruleResult.Condition)
+	}
+	assert.Equal(t, []string{"total_positive", "currency", "customer_required", "items_required"}, conditions)
+
+	fired := []string{}
+	for _, ruleResult := range evaluation.Fired() {
+		fired = append(fired, ruleResult.Condition)
+		assert.Equal(t, true, ruleResult.Value)
+		assert.Equal(t, ruleResult.Condition+"_true", ruleResult.Decision)
+	}
+	assert.Equal(t, []string{"total_positive", "customer_required", "items_required"}, fired)
+
+	failed := evaluation.Errors()
+	require.Len(t, failed, 1)
+	assert.Equal(t, "currency", failed[0].Condition)
+	assert.False(t, failed[0].Fired)
+	assert.Contains(t, failed[0].Err.Error(), "error evaluating check function")
+
+	assert.Equal(t, []string{"total must be positive", "customer is required", "items are required"}, result.Errors)
+}
+
+func TestRunner_EvaluateGroups(t *testing.T) {
+	rulesContext := OrderValidationContext{
+		Customer: "ACME",
+		Items:    []string{"book"},
+		Total:    10,
+		Currency: map[string]interface{}{"Code": "USD"},
+		Errors:   []string{},
+	}
+	runner := newOrderValidation(t, &rulesContext)
+
+	result, evaluation, err := runner.Evaluate(context.Background(), &rulesContext, "amounts")
+	require.NoError(t, err)
+	require.Len(t, evaluation.Results, 2)
+
+	assert.Equal(t, RuleResult{Condition: "total_positive", Value: false}, evaluation.Results[0])
+	assert.Equal(t, RuleResult{Condition: "currency", Fired: true, Value: "USD", Decision: "currency_default"}, evaluation.Results[1])
+	assert.Equal(t, []string{"unsupported currency"}, result.Errors)
+}
+
+func TestRunner_EvaluateCanceled(t *testing.T) {
+	rulesContext := OrderValidationContext{Errors: []string{}}
+	runner := newOrderValidation(t, &rulesContext)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, evaluation, err := runner.Evaluate(ctx, &rulesContext)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Empty(t, evaluation.Results)
+}
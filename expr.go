@@ -0,0 +1,82 @@
+package yabre
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// setSuffix is appended to the decision name to register the function applying its `set` assignments
+const setSuffix = ".set"
+
+var fieldPathRegex = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// exprPrefix precedes the `expr` of a condition on the first line of its check function
+const exprPrefix = "function() { return ("
+
+// exprFunction turns the `expr` of a condition into a check function
+func exprFunction(expr string) string {
+	return exprPrefix + expr + "\n); }"
+}
+
+// setFunction turns the `set` assignments of a decision into an action function.
+// Fields are dotted paths relative to the context; values are literals,
+// or javascript expressions when they are strings starting with `=`, which can use the parameters of the function.
+func setFunction(set map[string]interface{}, params ...string) (string, error) {
+	fields := make([]string, 0, len(set))
+	for field := range set {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var code strings.Builder
+	fmt.Fprintf(&code, "function(%s) {\n", strings.Join(params, ", "))
+	for _, field := range fields {
+		if !fieldPathRegex.MatchString(field) {
+			return "", fmt.Errorf("invalid field '%s'", field)
+		}
+
+		value, err := jsValue(set[field])
+		if err != nil {
+			return "", fmt.Errorf("invalid value of field '%s': %w", field, err)
+		}
+		fmt.Fprintf(&code, "context.%s = %s;\n", field, value)
+	}
+	code.WriteString("}")
+
+	return code.String(), nil
+}
+
+// jsValue returns the javascript code of a `set` value
+func jsValue(value interface{}) (string, error) {
+	if s, ok := value.(string); ok && strings.HasPrefix(s, "=") {
+		return "(" + strings.TrimPrefix(s, "=") + "\n)", nil
+	}
+
+	literal, err := json.Marshal(jsonValue(value))
+	if err != nil {
+		return "", err
+	}
+	return string(literal), nil
+}
+
+// jsonValue converts maps decoded from yaml to maps with string keys that can be marshaled to json
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = jsonValue(item)
+		}
+		return items
+	}
+	return value
+}
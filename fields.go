@@ -0,0 +1,179 @@
+package yabre
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/token"
+)
+
+// ContextFields lists the context fields a condition reads and writes as dotted paths relative to the context
+type ContextFields struct {
+	Reads  []string `json:"reads"`
+	Writes []string `json:"writes"`
+}
+
+// ContextFields analyzes the check, expr or decision table of every condition along with the actions and `set` assignments of its decisions
+// and returns the context fields each condition reads and writes, keyed by condition name.
+// The analysis follows `context.<field>` accesses in the rules themselves; fields accessed by functions of
+// the scripts section are not included. Functions that don't parse are skipped, Validate reports them.
+func (r *Rules) ContextFields() map[string]ContextFields {
+	fields := make(map[string]ContextFields, len(r.Conditions))
+	for name, condition := range r.Conditions {
+		collector := &fieldCollector{reads: map[string]bool{}, writes: map[string]bool{}}
+
+		check := condition.Check
+		if check == "" && condition.Expr != "" {
+			check = exprFunction(condition.Expr)
+		}
+		collector.collect(name, check)
+		if table, ok := r.Tables[condition.Table]; ok {
+			collector.collectTable(name, table)
+		}
+
+		for _, decision := range condition.decisions() {
+			collector.collect(decision.Name, decision.Action)
+			if len(decision.Set) > 0 {
+				if code, err := setFunction(decision.Set); err == nil {
+					collector.collect(decision.Name, code)
+				}
+			}
+		}
+
+		fields[name] = ContextFields{Reads: sortedKeys(collector.reads), Writes: sortedKeys(collector.writes)}
+	}
+	return fields
+}
+
+// fieldCollector collects the context fields accessed by javascript functions
+type fieldCollector struct {
+	reads  map[string]bool
+	writes map[string]bool
+}
+
+func (c *fieldCollector) collect(name, code string) {
+	if code == "" {
+		return
+	}
+	program, _, err := parseFunction(name, code)
+	if err != nil {
+		return
+	}
+	c.walk(reflect.ValueOf(program))
+}
+
+// collectTable records the input expressions and the `=` expressions of the rows of a decision table as reads
+// and its outputs as writes
+func (c *fieldCollector) collectTable(name string, table *DecisionTable) {
+	for _, input := range table.Inputs {
+		c.collect(name, exprFunction(input.Expr))
+	}
+	for _, row := range table.Rows {
+		for _, value := range append(append([]interface{}{}, row.When...), row.Then...) {
+			if s, ok := value.(string); ok && strings.HasPrefix(s, "=") {
+				c.collect(name, exprFunction(strings.TrimPrefix(s, "=")))
+			}
+		}
+	}
+	for _, output := range table.Outputs {
+		c.writes[output] = true
+	}
+}
+
+var fileType = reflect.TypeOf(&file.File{})
+
+// walk visits the nodes of the syntax tree, recording the context fields they access
+func (c *fieldCollector) walk(value reflect.Value) {
+	if !value.IsValid() || value.Type() == fileType {
+		return
+	}
+
+	if value.CanInterface() {
+		switch node := value.Interface().(type) {
+		case *ast.AssignExpression:
+			if path, ok := contextPath(node.Left); ok {
+				c.writes[path] = true
+				if node.Operator != token.ASSIGN {
+					c.reads[path] = true
+				}
+				c.walk(reflect.ValueOf(node.Right))
+				return
+			}
+		case *ast.UnaryExpression:
+			if path, ok := contextPath(node.Operand); ok && (node.Operator == token.INCREMENT || node.Operator == token.DECREMENT) {
+				c.reads[path] = true
+				c.writes[path] = true
+				return
+			}
+		case *ast.CallExpression:
+			// a method called on a field, such as `context.items.push(item)`, reads the field
+			if callee, ok := node.Callee.(*ast.DotExpression); ok {
+				if path, ok := contextPath(callee.Left); ok {
+					c.reads[path] = true
+					for _, argument := range node.ArgumentList {
+						c.walk(reflect.ValueOf(argument))
+					}
+					return
+				}
+			}
+		case *ast.DotExpression, *ast.BracketExpression:
+			if path, ok := contextPath(node.(ast.Expression)); ok {
+				c.reads[path] = true
+				return
+			}
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			c.walk(value.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			c.walk(value.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			c.walk(value.Index(i))
+		}
+	}
+}
+
+// contextPath returns the dotted path of a context field accessed by an expression such as `context.order.total`
+func contextPath(expression ast.Expression) (string, bool) {
+	var left ast.Expression
+	var field string
+	switch e := expression.(type) {
+	case *ast.DotExpression:
+		left, field = e.Left, string(e.Identifier.Name)
+	case *ast.BracketExpression:
+		member, ok := e.Member.(*ast.StringLiteral)
+		if !ok {
+			return "", false
+		}
+		left, field = e.Left, string(member.Value)
+	default:
+		return "", false
+	}
+
+	if identifier, ok := left.(*ast.Identifier); ok {
+		return field, identifier.Name == "context"
+	}
+	if path, ok := contextPath(left); ok {
+		return path + "." + field, true
+	}
+	return "", false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
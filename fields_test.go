@@ -0,0 +1,83 @@
+package yabre
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestContextFields(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/shipping_expr.yaml")
+	require.NoError(t, err)
+
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal(yamlFile, &rules))
+
+	fields := rules.ContextFields()
+	assert.Equal(t, ContextFields{
+		Reads:  []string{"Weight"},
+		Writes: []string{"Cost", "Method"},
+	}, fields["check_weight"])
+	assert.Equal(t, ContextFields{
+		Reads:  []string{"Cost", "Express", "Method", "Notes"},
+		Writes: []string{"Cost", "Tracking"},
+	}, fields["check_express"])
+}
+
+func TestContextFields_Tables(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/pricing_tables.yaml")
+	require.NoError(t, err)
+
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal(yamlFile, &rules))
+
+	fields := rules.ContextFields()
+	assert.Equal(t, ContextFields{
+		Reads:  []string{"CustomerType", "Total"},
+		Writes: []string{"Discount", "Reason"},
+	}, fields["apply_discount"])
+	assert.Equal(t, ContextFields{
+		Reads:  []string{"Country", "Express"},
+		Writes: []string{"Surcharges"},
+	}, fields["apply_surcharges"])
+}
+
+func TestContextFields_Functions(t *testing.T) {
+	yamlRules := `
+conditions:
+  start:
+    check: |
+      function() {
+        const limit = context.limits['daily'];
+        return context.order.total > limit && context[key] !== undefined;
+      }
+    true:
+      action: |
+        function() {
+          context.order.count++;
+          context.order.total += context.fee;
+          context.status = context.order.items.map(item => item.name).join(',');
+        }
+`
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(yamlRules), &rules))
+
+	assert.Equal(t, ContextFields{
+		Reads:  []string{"fee", "limits.daily", "order.count", "order.items", "order.total"},
+		Writes: []string{"order.count", "order.total", "status"},
+	}, rules.ContextFields()["start"])
+}
+
+func TestContextFields_LargeRules(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/aliquoting_rules.yaml")
+	require.NoError(t, err)
+
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal(yamlFile, &rules))
+
+	fields := rules.ContextFields()
+	assert.Len(t, fields, len(rules.Conditions))
+}
@@ -1,6 +1,7 @@
 package yabre
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -55,3 +56,14 @@ func checkVariadicAnySignature(fn any) (bool, error) {
 
 	return true, nil
 }
+
+// acceptsContext checks if the first argument of the given function is a context.Context
+func acceptsContext(fn any) bool {
+	fnType := reflect.TypeOf(fn)
+
+	if fnType == nil || fnType.Kind() != reflect.Func || fnType.NumIn() == 0 {
+		return false
+	}
+
+	return fnType.In(0) == reflect.TypeOf((*context.Context)(nil)).Elem()
+}
@@ -1,6 +1,7 @@
 package yabre
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -158,3 +159,24 @@ func TestCheckVariadicAnySignature(t *testing.T) {
 		})
 	}
 }
+
+func TestAcceptsContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       any
+		expected bool
+	}{
+		{"Context first", func(ctx context.Context, a int) int { return a }, true},
+		{"Context only", func(ctx context.Context) {}, true},
+		{"Context second", func(a int, ctx context.Context) {}, false},
+		{"No arguments", func() {}, false},
+		{"Variadic any", func(args ...any) (any, error) { return nil, nil }, false},
+		{"Non-function", "not a function", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, acceptsContext(tt.fn))
+		})
+	}
+}
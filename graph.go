@@ -0,0 +1,172 @@
+package yabre
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DependencyGraph is the graph of the rule sets of a library and the rule sets they require, as of their latest versions
+type DependencyGraph struct {
+	// maps every rule set to the names of the rule sets it requires directly, in the order of `require`
+	requires map[string][]string
+	// maps every rule set to the version constraints of its requirements, keyed by required rule set
+	constraints map[string]map[string]string
+	// latest versions of the rule sets, empty for rule sets without versions
+	versions map[string]string
+}
+
+// DependencyGraph returns the dependency graph of the current rule sets of the library
+func (rl *RulesLibrary) DependencyGraph() *DependencyGraph {
+	current := rl.current()
+	g := &DependencyGraph{
+		requires:    make(map[string][]string, len(current.dependencies)),
+		constraints: make(map[string]map[string]string, len(current.dependencies)),
+		versions:    make(map[string]string, len(current.versions)),
+	}
+	for name, deps := range current.dependencies {
+		g.requires[name] = []string{}
+		g.constraints[name] = map[string]string{}
+		for _, dep := range deps {
+			depName, constraint := splitRuleSetReference(dep)
+			g.requires[name] = append(g.requires[name], depName)
+			g.constraints[name][depName] = constraint
+		}
+	}
+	for name, versions := range current.versions {
+		g.versions[name] = latestVersion(versions).version
+	}
+	return g
+}
+
+// RuleSets returns the names of the rule sets of the graph, ordered by name
+func (g *DependencyGraph) RuleSets() []string {
+	names := make([]string, 0, len(g.requires))
+	for name := range g.requires {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Requires returns the rule sets the named rule set requires directly, in the order of its `require`
+func (g *DependencyGraph) Requires(name string) []string {
+	return append([]string{}, g.requires[name]...)
+}
+
+// AllRequires returns the rule sets the named rule set requires directly or through other rule sets, ordered by name
+func (g *DependencyGraph) AllRequires(name string) []string {
+	return g.reachable(name, g.Requires)
+}
+
+// Dependents returns the rule sets requiring the named rule set directly, ordered by name
+func (g *DependencyGraph) Dependents(name string) []string {
+	dependents := []string{}
+	for _, other := range g.RuleSets() {
+		for _, dep := range g.requires[other] {
+			if dep == name {
+				dependents = append(dependents, other)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// AllDependents returns the rule sets requiring the named rule set directly or through other rule sets, ordered by name.
+// They are the rule sets affected by a change of the named rule set.
+func (g *DependencyGraph) AllDependents(name string) []string {
+	return g.reachable(name, g.Dependents)
+}
+
+// reachable returns the rule sets reached from the named rule set following the edges, without the rule set itself
+func (g *DependencyGraph) reachable(name string, edges func(string) []string) []string {
+	found := map[string]bool{}
+	pending := edges(name)
+	for len(pending) > 0 {
+		next := pending[0]
+		pending = pending[1:]
+		if found[next] {
+			continue
+		}
+		found[next] = true
+		pending = append(pending, edges(next)...)
+	}
+	delete(found, name)
+
+	names := make([]string, 0, len(found))
+	for n := range found {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadOrder returns the order the named rule set and its dependencies are merged in: dependencies first, the rule set last.
+// Rule sets requiring each other fail with a *DependencyCycleError.
+func (g *DependencyGraph) LoadOrder(name string) ([]string, error) {
+	visited := map[string]bool{}
+	ordered := []string{}
+	path := []string{}
+
+	var visit func(string) error
+	visit = func(n string) error {
+		for i, p := range path {
+			if p == n {
+				return &DependencyCycleError{Cycle: append(append([]string{}, path[i:]...), n)}
+			}
+		}
+		if visited[n] {
+			return nil
+		}
+		if _, exists := g.requires[n]; !exists {
+			return fmt.Errorf("rule set %s not found", n)
+		}
+		visited[n] = true
+
+		path = append(path, n)
+		for _, dep := range g.requires[n] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+
+		ordered = append(ordered, n)
+		return nil
+	}
+
+	if err := visit(name); err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
+
+// Cycles returns the cycles of rule sets requiring each other, each starting and ending with its rule set with the lowest name
+func (g *DependencyGraph) Cycles() [][]string {
+	cycles := [][]string{}
+	seen := map[string]bool{}
+	for _, name := range g.RuleSets() {
+		_, err := g.LoadOrder(name)
+		var cycleError *DependencyCycleError
+		if !errors.As(err, &cycleError) {
+			continue
+		}
+
+		// rotate the cycle to start with its lowest rule set, so it is found once from any of its rule sets
+		cycle := cycleError.Cycle[:len(cycleError.Cycle)-1]
+		start := 0
+		for i := range cycle {
+			if cycle[i] < cycle[start] {
+				start = i
+			}
+		}
+		rotated := append(append(append([]string{}, cycle[start:]...), cycle[:start]...), cycle[start])
+		if key := strings.Join(rotated, " -> "); !seen[key] {
+			seen[key] = true
+			cycles = append(cycles, rotated)
+		}
+	}
+	return cycles
+}
@@ -0,0 +1,101 @@
+package yabre
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requiringRules(name string, require ...string) string {
+	rules := "name: " + name + "\n"
+	if len(require) > 0 {
+		rules += "require:\n"
+		for _, dep := range require {
+			rules += "  - " + dep + "\n"
+		}
+	}
+	return rules + "conditions: {}\n"
+}
+
+func TestRulesLibrary_DependencyGraph(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{
+		"checkout.yaml": {Data: []byte(requiringRules("checkout", "pricing", "shipping"))},
+		"pricing.yaml":  {Data: []byte(versionedPricingRules("1.0.0", "rates@^1"))},
+		"shipping.yaml": {Data: []byte(requiringRules("shipping", "rates", "zones"))},
+		"rates.yaml":    {Data: []byte(ratesRules("1.2.0", "1"))},
+		"zones.yaml":    {Data: []byte(requiringRules("zones"))},
+	}})
+	require.NoError(t, err)
+	g := rl.DependencyGraph()
+
+	assert.Equal(t, []string{"checkout", "pricing", "rates", "shipping", "zones"}, g.RuleSets())
+	assert.Equal(t, []string{"pricing", "shipping"}, g.Requires("checkout"))
+	assert.Equal(t, []string{"rates"}, g.Requires("pricing"))
+	assert.Equal(t, []string{}, g.Requires("zones"))
+	assert.Equal(t, []string{"pricing", "rates", "shipping", "zones"}, g.AllRequires("checkout"))
+	assert.Equal(t, []string{"pricing", "shipping"}, g.Dependents("rates"))
+	assert.Equal(t, []string{"checkout", "pricing", "shipping"}, g.AllDependents("rates"))
+	assert.Equal(t, []string{}, g.AllDependents("checkout"))
+	assert.Empty(t, g.Cycles())
+
+	order, err := g.LoadOrder("checkout")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rates", "pricing", "zones", "shipping", "checkout"}, order)
+	_, err = g.LoadOrder("missing")
+	assert.EqualError(t, err, "rule set missing not found")
+
+	expected := "flowchart LR\n" +
+		"    %% Rule sets\n" +
+		"    checkout[\"`checkout`\"]\n" +
+		"    pricing[\"`pricing 1.0.0`\"]\n" +
+		"    rates[\"`rates 1.2.0`\"]\n" +
+		"    shipping[\"`shipping`\"]\n" +
+		"    zones[\"`zones`\"]\n" +
+		"\n    %% Requires\n" +
+		"    checkout --> pricing\n" +
+		"    checkout --> shipping\n" +
+		"    pricing -->|\"`^1`\"| rates\n" +
+		"    shipping --> rates\n" +
+		"    shipping --> zones\n"
+	assert.Equal(t, expected, ExportDependencyMermaid(rl))
+}
+
+func TestRulesLibrary_DependencyCycles(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{
+		"main.yaml":    {Data: []byte(requiringRules("main", "orders"))},
+		"orders.yaml":  {Data: []byte(requiringRules("orders", "billing", "missing"))},
+		"billing.yaml": {Data: []byte(requiringRules("billing", "tax"))},
+		"tax.yaml":     {Data: []byte(requiringRules("tax", "orders"))},
+		"a.yaml":       {Data: []byte(requiringRules("a", "a"))},
+	}})
+	require.NoError(t, err)
+
+	_, err = rl.LoadRules("main")
+	var cycleError *DependencyCycleError
+	require.True(t, errors.As(err, &cycleError), err)
+	assert.Equal(t, []string{"orders", "billing", "tax", "orders"}, cycleError.Cycle)
+	assert.EqualError(t, err, "failed to resolve dependencies: dependency cycle: orders -> billing -> tax -> orders")
+
+	g := rl.DependencyGraph()
+	assert.Equal(t, [][]string{{"a", "a"}, {"billing", "tax", "orders", "billing"}}, g.Cycles())
+	_, err = g.LoadOrder("tax")
+	assert.EqualError(t, err, "dependency cycle: tax -> orders -> billing -> tax")
+
+	// cycles are reported by ValidateAll even when no other rule set requires them
+	var validationError *ValidationError
+	require.True(t, errors.As(rl.ValidateAll(), &validationError))
+	messages := []string{}
+	for _, issue := range validationError.Issues {
+		messages = append(messages, issue.File+": "+issue.Message)
+	}
+	assert.Equal(t, []string{
+		"a.yaml: dependency cycle: a -> a",
+		"billing.yaml: dependency cycle: billing -> tax -> orders -> billing",
+		"main.yaml: failed to resolve dependencies: dependency cycle: orders -> billing -> tax -> orders",
+	}, messages)
+
+	assert.Contains(t, ExportDependencyMermaid(rl), "    missing[\"`missing (not found)`\"]\n")
+}
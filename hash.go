@@ -0,0 +1,52 @@
+package yabre
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+// contentHash returns the hash of the contents of rule files, `sha256:<hex>`, in the order they are merged.
+// Every content is prefixed with its length, so moving bytes from one file to the next changes the hash.
+func contentHash(contents ...[]byte) string {
+	hash := sha256.New()
+	for _, content := range contents {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(content)))
+		hash.Write(length[:])
+		hash.Write(content)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// signatureSuffix is appended to the path of a rule file to get the path of its detached signature
+const signatureSuffix = ".sig"
+
+// verifySignature checks the detached signature of the rule file at path against the keys of the library
+func (rl *RulesLibrary) verifySignature(ctx context.Context, path string, data []byte) error {
+	signature, err := rl.source.Read(ctx, path+signatureSuffix)
+	if err != nil {
+		return &SignatureError{File: path, Err: err}
+	}
+
+	// signatures are stored raw or base64 encoded
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+		if err != nil {
+			return &SignatureError{File: path, Err: errors.New("signature is neither raw nor base64 encoded")}
+		}
+		signature = decoded
+	}
+
+	for _, key := range rl.signatureKeys {
+		if ed25519.Verify(key, data, signature) {
+			return nil
+		}
+	}
+	return &SignatureError{File: path, Err: errors.New("signature doesn't match the file")}
+}
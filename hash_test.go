@@ -0,0 +1,94 @@
+package yabre
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesHash(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"rates_1.yaml":   {Data: []byte(ratesRules("1.0.0", "1"))},
+		"rates_2.yaml":   {Data: []byte(ratesRules("2.0.0", "2"))},
+		"pricing_1.yaml": {Data: []byte(versionedPricingRules("1.0.0", "rates@1"))},
+		"pricing_2.yaml": {Data: []byte(versionedPricingRules("2.0.0", "rates@2"))},
+	}
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+
+	rulesContext := ReloadContext{}
+	runner, err := NewRulesRunnerFromLibrary(rl, "pricing@1", &rulesContext)
+	require.NoError(t, err)
+	expected := contentHash(fileSystem["rates_1.yaml"].Data, fileSystem["pricing_1.yaml"].Data)
+	assert.Equal(t, expected, runner.RulesHash())
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", runner.RulesHash())
+
+	_, trace, err := runner.RunRulesWithTrace(context.Background(), &rulesContext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, expected, trace.RulesHash)
+
+	// the hash is stable and changes with the rules and with their dependencies
+	again, err := rl.LoadRules("pricing@1")
+	require.NoError(t, err)
+	assert.Equal(t, expected, again.Hash)
+	latest, err := rl.LoadRules("pricing")
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, latest.Hash)
+	rates, err := rl.LoadRules("rates@1")
+	require.NoError(t, err)
+	assert.Equal(t, contentHash(fileSystem["rates_1.yaml"].Data), rates.Hash)
+
+	yamlRunner, err := NewRulesRunnerFromYaml(fileSystem["rates_1.yaml"].Data, &rulesContext)
+	require.NoError(t, err)
+	assert.Equal(t, rates.Hash, yamlRunner.RulesHash())
+
+	// contents are delimited, so moving bytes between files changes the hash
+	assert.NotEqual(t, contentHash([]byte("ab"), []byte("c")), contentHash([]byte("a"), []byte("bc")))
+}
+
+func TestRulesLibrary_SignatureKeys(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	pricing := []byte(pricingRules("1"))
+	source := NewMemorySource(map[string][]byte{
+		"pricing.yaml": pricing,
+		// signatures can be raw or base64 encoded
+		"pricing.yaml.sig": ed25519.Sign(privateKey, pricing),
+	})
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{Source: source, SignatureKeys: []ed25519.PublicKey{otherKey, publicKey}})
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, runPricing(t, rl))
+
+	_, err = NewRulesLibrary(RulesLibrarySettings{Source: source, SignatureKeys: []ed25519.PublicKey{otherKey}})
+	assert.EqualError(t, err, "failed to scan files: invalid signature of rule file pricing.yaml: signature doesn't match the file")
+
+	// tampered files are rejected on reload and the library keeps its verified rules
+	source.Set("pricing.yaml", []byte(pricingRules("2")))
+	err = rl.Reload()
+	var signatureError *SignatureError
+	require.True(t, errors.As(err, &signatureError))
+	assert.Equal(t, "pricing.yaml", signatureError.File)
+	assert.Equal(t, 1.0, runPricing(t, rl))
+
+	// and accepted once signed
+	updated := []byte(pricingRules("2"))
+	source.Set("pricing.yaml.sig", []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, updated))+"\n"))
+	require.NoError(t, rl.Reload())
+	assert.Equal(t, 2.0, runPricing(t, rl))
+
+	source.Delete("pricing.yaml.sig")
+	assert.ErrorContains(t, rl.Reload(), "invalid signature of rule file pricing.yaml: file pricing.yaml.sig not found")
+
+	source.Set("pricing.yaml.sig", []byte("not a signature"))
+	assert.ErrorContains(t, rl.Reload(), "signature is neither raw nor base64 encoded")
+}
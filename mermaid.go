@@ -2,6 +2,9 @@ package yabre
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -22,6 +25,52 @@ func ExportMermaidFromLibrary(library *RulesLibrary, ruleName string, defaultCon
 	return ExportMermaid(yamlData, defaultConditionName)
 }
 
+// ExportDependencyMermaid renders the dependency graph of the rule sets of a library as a Mermaid flowchart,
+// with an edge from every rule set to each rule set it requires, labeled with its version constraint if any
+func ExportDependencyMermaid(library *RulesLibrary) string {
+	g := library.DependencyGraph()
+
+	var mermaid strings.Builder
+	mermaid.WriteString("flowchart LR\n")
+	mermaid.WriteString("    %% Rule sets\n")
+
+	missing := map[string]bool{}
+	for _, name := range g.RuleSets() {
+		label := name
+		if g.versions[name] != "" {
+			label += " " + g.versions[name]
+		}
+		fmt.Fprintf(&mermaid, "    %s[\"`%s`\"]\n", nodeID(name), escape(label))
+		for _, dep := range g.requires[name] {
+			if _, exists := g.requires[dep]; !exists {
+				missing[dep] = true
+			}
+		}
+	}
+
+	missingNames := make([]string, 0, len(missing))
+	for name := range missing {
+		missingNames = append(missingNames, name)
+	}
+	sort.Strings(missingNames)
+	for _, name := range missingNames {
+		fmt.Fprintf(&mermaid, "    %s[\"`%s (not found)`\"]\n", nodeID(name), escape(name))
+	}
+
+	mermaid.WriteString("\n    %% Requires\n")
+	for _, name := range g.RuleSets() {
+		for _, dep := range g.requires[name] {
+			if constraint := g.constraints[name][dep]; constraint != "" {
+				fmt.Fprintf(&mermaid, "    %s -->|\"`%s`\"| %s\n", nodeID(name), escape(constraint), nodeID(dep))
+			} else {
+				fmt.Fprintf(&mermaid, "    %s --> %s\n", nodeID(name), nodeID(dep))
+			}
+		}
+	}
+
+	return mermaid.String()
+}
+
 func ExportMermaid(yamlString []byte, defaultConditionName string) (string, error) {
 	// Parse the YAML into a Rule struct
 	var rules Rules
@@ -36,24 +85,54 @@ func ExportMermaid(yamlString []byte, defaultConditionName string) (string, erro
 
 	// Declare all elements
 	for _, condition := range rules.Conditions {
-		fmt.Fprintf(&mermaid, "    %s{\"`%s`\"}\n", condition.Name, escape(ifEmpty(condition.Description, condition.Name)))
+		id := nodeID(condition.Name)
+		fmt.Fprintf(&mermaid, "    %s{\"`%s`\"}\n", id, escape(ifEmpty(condition.Description, condition.Name)))
+		if condition.Table != "" {
+			fmt.Fprintf(&mermaid, "    %s_table[/\"`table %s`\"/]\n", id, escape(condition.Table))
+		}
 		if condition.True != nil {
 			if condition.True.Action != "" {
-				fmt.Fprintf(&mermaid, "    %s_true[\"`%s`\"]\n", condition.Name, escape(ifEmpty(condition.True.Description, condition.Name+"_true")))
+				fmt.Fprintf(&mermaid, "    %s_true[\"`%s`\"]\n", id, escape(ifEmpty(condition.True.Description, condition.Name+"_true")))
+			}
+
+			if condition.True.Call != "" {
+				fmt.Fprintf(&mermaid, "    %s_true_call[[\"`%s`\"]]\n", id, escape(condition.True.Call))
 			}
 
 			if condition.True.Terminate {
-				fmt.Fprintf(&mermaid, "    %s_true_end((( )))\n", condition.Name)
+				fmt.Fprintf(&mermaid, "    %s_true_end((( )))\n", id)
 			}
 		}
 
 		if condition.False != nil {
 			if condition.False.Action != "" {
-				fmt.Fprintf(&mermaid, "    %s_false[\"%s\"]\n", condition.Name, escape(ifEmpty(condition.False.Description, condition.Name+"_false")))
+				fmt.Fprintf(&mermaid, "    %s_false[\"%s\"]\n", id, escape(ifEmpty(condition.False.Description, condition.Name+"_false")))
+			}
+
+			if condition.False.Call != "" {
+				fmt.Fprintf(&mermaid, "    %s_false_call[[\"`%s`\"]]\n", id, escape(condition.False.Call))
 			}
 
 			if condition.False.Terminate {
-				fmt.Fprintf(&mermaid, "    %s_false_end((( )))\n", condition.Name)
+				fmt.Fprintf(&mermaid, "    %s_false_end((( )))\n", id)
+			}
+		}
+
+		for _, decision := range condition.decisions() {
+			if decision.Case == "" && !decision.errorHandler {
+				continue
+			}
+
+			if decision.Action != "" {
+				fmt.Fprintf(&mermaid, "    %s[\"`%s`\"]\n", nodeID(decision.Name), escape(ifEmpty(decision.Description, decision.Name)))
+			}
+
+			if decision.Call != "" {
+				fmt.Fprintf(&mermaid, "    %s_call[[\"`%s`\"]]\n", nodeID(decision.Name), escape(decision.Call))
+			}
+
+			if decision.Terminate {
+				fmt.Fprintf(&mermaid, "    %s_end((( )))\n", nodeID(decision.Name))
 			}
 		}
 	}
@@ -67,11 +146,13 @@ func ExportMermaid(yamlString []byte, defaultConditionName string) (string, erro
 }
 
 func renderCondition(condition *Condition, mermaid *strings.Builder) error {
-	if condition.True != nil {
-		renderDecision(condition, condition.True, mermaid)
+	if condition.Table != "" {
+		// link from condition to the decision table it evaluates
+		fmt.Fprintf(mermaid, "    %s -.- %s_table\n", nodeID(condition.Name), nodeID(condition.Name))
 	}
-	if condition.False != nil {
-		renderDecision(condition, condition.False, mermaid)
+
+	for _, decision := range condition.decisions() {
+		renderDecision(condition, decision, mermaid)
 	}
 
 	return nil
@@ -82,33 +163,48 @@ func renderDecision(
 	decision *Decision,
 	mermaid *strings.Builder) error {
 
+	value := strconv.FormatBool(decision.Value)
+	if decision.Case != "" {
+		value = escape(decision.Case)
+	}
+	if decision.errorHandler {
+		value = "on_error"
+	}
+
+	// the decision leads from the condition through its action and called rule set, if any
+	from, label, id := nodeID(condition.Name), fmt.Sprintf("|%s| ", value), nodeID(decision.Name)
+
 	if decision.Action != "" {
-		// connection from condition to True/False action
-		fmt.Fprintf(mermaid, "    %s --> |%t| %s\n", condition.Name, decision.Value, decision.Name)
+		// connection from condition to the decision action
+		fmt.Fprintf(mermaid, "    %s --> %s%s\n", from, label, id)
+		from, label = id, ""
+	}
 
-		if decision.Next != "" {
-			// connection from True/False action to next condition
-			fmt.Fprintf(mermaid, "    %s --> %s\n", decision.Name, decision.Next)
-		}
+	if decision.Call != "" {
+		// connection to the called rule set
+		fmt.Fprintf(mermaid, "    %s --> %s%s_call\n", from, label, id)
+		from, label = id+"_call", ""
+	}
 
-		if decision.Terminate {
-			// terminator from True/False action
-			fmt.Fprintf(mermaid, "    %s --> %s_end\n", decision.Name, decision.Name)
-		}
-	} else {
-		if decision.Next != "" {
-			// connection from condition to next condition
-			fmt.Fprintf(mermaid, "    %s --> |%t| %s\n", condition.Name, decision.Value, decision.Next)
-		}
+	if decision.Next != "" {
+		// connection to next condition
+		fmt.Fprintf(mermaid, "    %s --> %s%s\n", from, label, nodeID(decision.Next))
+	}
 
-		if decision.Terminate {
-			// terminator from condition
-			fmt.Fprintf(mermaid, "    %s --> |%t| %s_end\n", condition.Name, decision.Value, decision.Name)
-		}
+	if decision.Terminate {
+		// terminator
+		fmt.Fprintf(mermaid, "    %s --> %s%s_end\n", from, label, id)
 	}
 	return nil
 }
 
+var nodeIDRegex = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// nodeID turns a condition or decision name, which may hold case values or namespaces, into a mermaid node id
+func nodeID(name string) string {
+	return nodeIDRegex.ReplaceAllString(name, "_")
+}
+
 func ifEmpty(first, second string) string {
 	if first == "" {
 		return second
@@ -44,3 +44,42 @@ func TestExportMermaidFromLibrary(t *testing.T) {
 	assert.Contains(t, mermaidCode, "check_for_ruleset2 --> |true| execute_ruleset2")
 	assert.Contains(t, mermaidCode, "check_for_ruleset2 --> |false| execute_ruleset3")
 }
+
+func TestExportMermaidWithCalls(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "test/calls"})
+	assert.NoError(t, err)
+
+	mermaidCode, err := ExportMermaidFromLibrary(rl, "orders", "start")
+	assert.NoError(t, err)
+
+	// called rule sets are rendered as subroutines between the decision and its next condition
+	assert.Contains(t, mermaidCode, "start_true_call[[\"`discount`\"]]")
+	assert.Contains(t, mermaidCode, "start --> |true| start_true_call")
+	assert.Contains(t, mermaidCode, "start_true_call --> finish")
+	assert.Contains(t, mermaidCode, "eligible --> |true| eligible_true")
+	assert.Contains(t, mermaidCode, "eligible_true --> eligible_true_call")
+	assert.Contains(t, mermaidCode, "eligible_true_call --> eligible_true_end")
+}
+
+func TestExportMermaidWithCases(t *testing.T) {
+	yamlString, err := os.ReadFile("test/bre/main_switch.yaml")
+	assert.NoError(t, err)
+
+	mermaidCode, err := ExportMermaid(yamlString, "route")
+	assert.NoError(t, err)
+
+	assert.Contains(t, mermaidCode, "route --> |ruleset1| execute_ruleset1")
+	assert.Contains(t, mermaidCode, "route --> |ruleset2| execute_ruleset2")
+	assert.Contains(t, mermaidCode, "route --> |default| execute_ruleset3")
+}
+
+func TestExportMermaidWithOnError(t *testing.T) {
+	yamlString, err := os.ReadFile("test/rates_on_error.yaml")
+	assert.NoError(t, err)
+
+	mermaidCode, err := ExportMermaid(yamlString, "fetch_rate")
+	assert.NoError(t, err)
+
+	assert.Contains(t, mermaidCode, "fetch_rate --> |on_error| use_default_rate")
+	assert.Contains(t, mermaidCode, "fetch_rate --> |true| convert")
+}
@@ -0,0 +1,77 @@
+package yabre
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// runGuarded runs a condition like runCondition and handles its failure with the on_error decision
+// of the condition or, if it has none, of its rule set or of the rules
+func (exec *execution[Context]) runGuarded(condition *Condition) (*Condition, error) {
+	next, err := exec.runCondition(condition)
+	if err == nil {
+		return next, nil
+	}
+
+	handler := condition.OnError
+	if handler == nil {
+		handler = exec.rules.errorHandler(condition.RuleSet)
+	}
+	if handler == nil {
+		return nil, err
+	}
+
+	for retry := 1; retry <= handler.Retry && exec.recoverable(err); retry++ {
+		exec.runner.decisionCallback("Retrying condition: [%s] after error: %v", condition.Name, err)
+		exec.trace.record(exec.event(TraceRetry, TraceEvent{Decision: handler.Name, Error: err.Error()}))
+		if next, err = exec.runCondition(condition); err == nil {
+			return next, nil
+		}
+	}
+
+	if !exec.recoverable(err) {
+		return nil, err
+	}
+
+	exec.runner.decisionCallback("Handling error of condition: [%s] %v", condition.Name, err)
+	exec.current = condition
+	exec.trace.record(exec.event(TraceOnError, TraceEvent{Decision: handler.Name, Error: err.Error()}))
+
+	if actErr := exec.act(handler, exec.errorValue(condition, err)); actErr != nil {
+		return nil, fmt.Errorf("error in on_error decision while handling %v: %w", err, actErr)
+	}
+
+	if handler.Call != "" {
+		return exec.call(handler)
+	}
+	return exec.proceed(handler)
+}
+
+// recoverable reports whether an error can be handled by an on_error decision:
+// runs stopped by their context or their step budget can't recover
+func (exec *execution[Context]) recoverable(err error) bool {
+	var cycleErr *CycleError
+	return exec.ctx.Err() == nil && !errors.As(err, &cycleErr)
+}
+
+// errorValue returns the `error` object passed to on_error decisions: the message of the error,
+// the condition that failed and the original error: the thrown value of javascript exceptions, the innermost message otherwise
+func (exec *execution[Context]) errorValue(condition *Condition, err error) goja.Value {
+	value := exec.vm.NewObject()
+	_ = value.Set("message", err.Error())
+	_ = value.Set("condition", condition.Name)
+
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		_ = value.Set("original", exception.Value())
+	} else {
+		original := err
+		for errors.Unwrap(original) != nil {
+			original = errors.Unwrap(original)
+		}
+		_ = value.Set("original", original.Error())
+	}
+	return value
+}
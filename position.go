@@ -0,0 +1,180 @@
+package yabre
+
+import (
+	"fmt"
+	"strings"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// Position locates a condition, decision or script in a rules file; lines and columns start at 1
+type Position struct {
+	// File is the path of the rules file, if loaded from a library
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// IsValid reports whether the position is known
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("line %d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// offset returns the position in the file of a line and column of javascript starting at p.
+// Block scalars strip the same indentation from all their lines, so columns are shifted the same way on every line.
+func (p Position) offset(line, column int) Position {
+	return Position{File: p.File, Line: p.Line + line - 1, Column: p.Column + column - 1}
+}
+
+// locate records the positions of the conditions, decisions and scripts of rules parsed from data.
+// Positions are best effort: the rules are parsed already, so a document that can't be walked leaves them unknown.
+func (r *Rules) locate(file string, data []byte) {
+	var document yaml3.Node
+	if err := yaml3.Unmarshal(data, &document); err != nil || len(document.Content) == 0 {
+		return
+	}
+	lines := strings.Split(string(data), "\n")
+	root := document.Content[0]
+
+	if _, scripts := mappingEntry(root, "scripts"); scripts != nil {
+		r.scriptsAt = sourcePosition(file, lines, scripts)
+	}
+	if key, onError := mappingEntry(root, "on_error"); onError != nil && r.OnError != nil {
+		locateDecision(r.OnError, file, lines, key, onError)
+	}
+
+	_, conditions := mappingEntry(root, "conditions")
+	if conditions == nil || conditions.Kind != yaml3.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(conditions.Content); i += 2 {
+		key, node := conditions.Content[i], conditions.Content[i+1]
+		condition, ok := r.Conditions[key.Value]
+		if !ok {
+			continue
+		}
+
+		condition.Position = nodePosition(file, key)
+		if _, check := mappingEntry(node, "check"); check != nil {
+			condition.source = sourcePosition(file, lines, check)
+		} else if _, expr := mappingEntry(node, "expr"); expr != nil {
+			condition.source = sourcePosition(file, lines, expr)
+		}
+
+		branches := map[string]*Decision{"true": condition.True, "false": condition.False, "on_error": condition.OnError}
+		for branch, decision := range branches {
+			if key, value := mappingEntry(node, branch); decision != nil && value != nil {
+				locateDecision(decision, file, lines, key, value)
+			}
+		}
+		if _, cases := mappingEntry(node, "cases"); cases != nil && cases.Kind == yaml3.MappingNode {
+			for j := 0; j+1 < len(cases.Content); j += 2 {
+				if decision := condition.Cases[cases.Content[j].Value]; decision != nil {
+					locateDecision(decision, file, lines, cases.Content[j], cases.Content[j+1])
+				}
+			}
+		}
+
+		r.Conditions[key.Value] = condition
+		if r.DefaultCondition != nil && r.DefaultCondition.Name == condition.Name {
+			r.DefaultCondition.Position, r.DefaultCondition.source = condition.Position, condition.source
+		}
+	}
+}
+
+// locateDecision records the position of a decision at its key and the position of its action
+func locateDecision(decision *Decision, file string, lines []string, key, node *yaml3.Node) {
+	decision.Position = nodePosition(file, key)
+	if _, action := mappingEntry(node, "action"); action != nil {
+		decision.source = sourcePosition(file, lines, action)
+	}
+}
+
+// mappingEntry returns the key and value nodes of a mapping entry, or nil if the node isn't a mapping or has no such key
+func mappingEntry(node *yaml3.Node, name string) (*yaml3.Node, *yaml3.Node) {
+	if node.Kind != yaml3.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func nodePosition(file string, node *yaml3.Node) Position {
+	return Position{File: file, Line: node.Line, Column: node.Column}
+}
+
+// sourcePosition returns the position of the first character of the javascript held by a scalar node.
+// The content of block scalars starts on the line after their `|` or `>` indicator.
+func sourcePosition(file string, lines []string, node *yaml3.Node) Position {
+	switch {
+	case node.Style&(yaml3.LiteralStyle|yaml3.FoldedStyle) != 0:
+		line := node.Line + 1
+		if line > len(lines) {
+			return nodePosition(file, node)
+		}
+		indent := len(lines[line-1]) - len(strings.TrimLeft(lines[line-1], " "))
+		return Position{File: file, Line: line, Column: indent + 1}
+	case node.Style&(yaml3.DoubleQuotedStyle|yaml3.SingleQuotedStyle) != 0:
+		return Position{File: file, Line: node.Line, Column: node.Column + 1}
+	default:
+		return nodePosition(file, node)
+	}
+}
+
+// locateError sets the position in the rules file of a javascript error raised by the function named key,
+// whose javascript starts at source after prefix generated characters, or by the scripts of the rules.
+// Errors located elsewhere, like in generated functions, are positioned at the condition or decision.
+func (r *Rules) locateError(scriptErr *ScriptError, key string, source Position, prefix int, at Position) *ScriptError {
+	line, column := scriptErr.Line, scriptErr.Column
+	if line == 0 {
+		scriptErr.Position = at
+		return scriptErr
+	}
+	if scriptErr.Source == key && source.IsValid() {
+		if line == 1 {
+			column -= prefix
+		}
+		scriptErr.Position = source.offset(line, column)
+		return scriptErr
+	}
+	if position, ok := r.scriptsPosition(scriptErr.Source, line, column); ok {
+		scriptErr.Position = position
+		return scriptErr
+	}
+	scriptErr.Position = at
+	return scriptErr
+}
+
+// scriptsPosition returns the position in its rules file of a line and column of the scripts compiled as source:
+// the merged shared scripts compiled under the rules name, or the scope of a namespaced rule set compiled under its file
+func (r *Rules) scriptsPosition(source string, line, column int) (Position, bool) {
+	first := 1
+	for _, scripts := range r.scriptSources() {
+		lines := strings.Count(scripts.scripts, "\n") + 1
+		switch {
+		case scripts.namespace == "" && source == r.Name:
+			// shared scripts are joined by new lines in the order of their sources
+			if line < first+lines {
+				return scripts.at.offset(line-first+1, column), scripts.at.IsValid()
+			}
+			first += lines
+		case scripts.namespace != "" && source == scripts.file:
+			// the scope starts with a line wrapping the scripts into a function
+			if line > 1 && line <= lines+1 {
+				return scripts.at.offset(line-1, column), scripts.at.IsValid()
+			}
+		}
+	}
+	return Position{}, false
+}
@@ -0,0 +1,210 @@
+package yabre
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const positionRules = `name: positions
+scripts: |
+  function limit() {
+    return context.limits.max;
+  }
+on_error:
+  terminate: true
+conditions:
+  start:
+    default: true
+    check: |
+      function() {
+        return context.total > limit();
+      }
+    true:
+      action: "function() { context.flag = true; }"
+      next: route
+    false:
+      terminate: true
+  route:
+    expr: context.customer.type
+    cases:
+      gold:
+        terminate: true
+      default:
+        set:
+          discount: 0
+        terminate: true
+    on_error:
+      terminate: true
+`
+
+func TestRulesLibrary_Positions(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{"positions.yaml": {Data: []byte(positionRules)}}})
+	require.NoError(t, err)
+
+	rules, err := rl.LoadRules("positions")
+	require.NoError(t, err)
+
+	start := rules.Conditions["start"]
+	assert.Equal(t, Position{File: "positions.yaml", Line: 9, Column: 3}, start.Position)
+	assert.Equal(t, Position{File: "positions.yaml", Line: 12, Column: 7}, start.source)
+	assert.Equal(t, start.Position, rules.DefaultCondition.Position)
+	assert.Equal(t, Position{File: "positions.yaml", Line: 15, Column: 5}, start.True.Position)
+	assert.Equal(t, Position{File: "positions.yaml", Line: 16, Column: 16}, start.True.source)
+	assert.Equal(t, Position{File: "positions.yaml", Line: 18, Column: 5}, start.False.Position)
+
+	route := rules.Conditions["route"]
+	assert.Equal(t, Position{File: "positions.yaml", Line: 20, Column: 3}, route.Position)
+	assert.Equal(t, Position{File: "positions.yaml", Line: 21, Column: 11}, route.source)
+	assert.Equal(t, Position{File: "positions.yaml", Line: 23, Column: 7}, route.Cases["gold"].Position)
+	assert.Equal(t, Position{File: "positions.yaml", Line: 25, Column: 7}, route.Cases["default"].Position)
+	assert.Equal(t, Position{File: "positions.yaml", Line: 29, Column: 5}, route.OnError.Position)
+
+	assert.Equal(t, Position{File: "positions.yaml", Line: 6, Column: 1}, rules.OnError.Position)
+	assert.Equal(t, Position{File: "positions.yaml", Line: 3, Column: 3}, rules.scriptSources()[0].at)
+}
+
+func TestScriptError_Positions(t *testing.T) {
+	tests := []struct {
+		name     string
+		context  map[string]interface{}
+		position Position
+	}{
+		{
+			name:     "Scripts",
+			context:  map[string]interface{}{"total": 10},
+			position: Position{File: "positions.yaml", Line: 4, Column: 27},
+		},
+		{
+			name:     "Expr",
+			context:  map[string]interface{}{"total": 10, "limits": map[string]interface{}{"max": 5}},
+			position: Position{File: "positions.yaml", Line: 21, Column: 28},
+		},
+	}
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{"positions.yaml": {Data: []byte(positionRules)}}})
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the handled error of route is reported through the decision callback
+			var handled error
+			runner, err := NewRulesRunnerFromLibrary(rl, "positions", &tt.context,
+				WithDecisionCallback[map[string]interface{}](func(msg string, args ...interface{}) {
+					if msg == "Handling error of condition: [%s] %v" {
+						handled = args[1].(error)
+					}
+				}))
+			require.NoError(t, err)
+
+			_, err = runner.RunRules(&tt.context, nil)
+			require.NoError(t, err)
+			require.Error(t, handled)
+
+			var scriptErr *ScriptError
+			require.True(t, errors.As(handled, &scriptErr), handled)
+			assert.Equal(t, tt.position, scriptErr.Position)
+			assert.Contains(t, scriptErr.Error(), tt.position.String()+": condition ")
+		})
+	}
+}
+
+func TestScriptError_NamespacedScriptsPosition(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"main.yaml": {Data: []byte(`name: main
+require:
+  - tax
+conditions:
+  start:
+    default: true
+    check: "function() { return tax.rate() > 0; }"
+    true:
+      terminate: true
+`)},
+		"tax.yaml": {Data: []byte(`name: tax
+namespaced: true
+exports:
+  - rate
+scripts: |
+  function rate() {
+    return context.tax.rate;
+  }
+`)},
+	}
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(rl, "main", &rulesContext)
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	var scriptErr *ScriptError
+	require.True(t, errors.As(err, &scriptErr), err)
+	assert.Equal(t, Position{File: "tax.yaml", Line: 7, Column: 24}, scriptErr.Position)
+}
+
+func TestValidate_Positions(t *testing.T) {
+	fileSystem := fstest.MapFS{"invalid.yaml": {Data: []byte(`name: invalid
+conditions:
+  start:
+    default: true
+    check: |
+      function() {
+        return 1 +;
+      }
+    true:
+      next: missing
+  orphan:
+    expr: "true"
+    true:
+      terminate: true
+`)}}
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+
+	err = rl.ValidateAll()
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr), err)
+	require.Len(t, validationErr.Issues, 3)
+
+	assert.Equal(t, "invalid.yaml:11:3: condition 'orphan': unreachable from default condition", validationErr.Issues[0].String())
+	assert.Equal(t, 7, validationErr.Issues[1].Line)
+	assert.Equal(t, 19, validationErr.Issues[1].Column)
+	assert.Contains(t, validationErr.Issues[1].String(), "invalid.yaml:7:19: condition 'start': invalid check function")
+	assert.Equal(t, "invalid.yaml:9:5: condition 'start': next condition 'missing' of true branch not found", validationErr.Issues[2].String())
+}
+
+func TestRunRulesWithTrace_ErrorPosition(t *testing.T) {
+	fileSystem := fstest.MapFS{"failing.yaml": {Data: []byte(`name: failing
+conditions:
+  start:
+    default: true
+    check: "function() { return context.order.total > 0; }"
+    true:
+      terminate: true
+`)}}
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(rl, "failing", &rulesContext)
+	require.NoError(t, err)
+
+	_, trace, err := runner.RunRulesWithTrace(context.Background(), &rulesContext, nil)
+	require.Error(t, err)
+
+	assert.Equal(t, TraceCondition, trace.Events[0].Type)
+	assert.Equal(t, 3, trace.Events[0].Line)
+	assert.Equal(t, 3, trace.Events[0].Column)
+
+	last := trace.Events[len(trace.Events)-1]
+	assert.Equal(t, TraceError, last.Type)
+	assert.Equal(t, "failing.yaml", last.File)
+	assert.Equal(t, 5, last.Line)
+	assert.Equal(t, 47, last.Column)
+}
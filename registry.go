@@ -0,0 +1,452 @@
+package yabre
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+)
+
+// ruleFunction is a check or action function of the rules
+type ruleFunction struct {
+	// name of the condition or decision the function belongs to
+	key      string
+	code     string
+	isAction bool
+	// namespace of the rule set the function comes from, if it is namespaced
+	namespace string
+	// condition the function belongs to and file it comes from, for error reporting
+	condition string
+	file      string
+	// position of the javascript in the file and number of characters generated before it on its first line,
+	// and position of the condition or decision, for error reporting
+	source Position
+	prefix int
+	at     Position
+}
+
+// functions lists all check and action functions of the rules ordered by condition or decision name,
+// including the functions generated for `expr` checks, decision tables and `set` assignments
+func (r *Rules) functions() ([]ruleFunction, error) {
+	functions := []ruleFunction{}
+	for _, condition := range r.Conditions {
+		first := len(functions)
+		if condition.Check != "" {
+			functions = append(functions, ruleFunction{key: condition.Name, code: condition.Check, namespace: condition.Namespace, source: condition.source})
+		} else if condition.Expr != "" {
+			functions = append(functions, ruleFunction{key: condition.Name, code: exprFunction(condition.Expr), namespace: condition.Namespace,
+				source: condition.source, prefix: condition.sourcePrefix()})
+		} else if condition.Table != "" {
+			table, ok := r.Tables[condition.Table]
+			if !ok {
+				return nil, fmt.Errorf("table %s of condition %s not found", condition.Table, condition.Name)
+			}
+			code, err := tableFunction(table)
+			if err != nil {
+				return nil, fmt.Errorf("error in table %s: %w", table.Name, err)
+			}
+			functions = append(functions, ruleFunction{key: condition.Name, code: code, namespace: condition.Namespace})
+		}
+		if len(functions) > first {
+			functions[first].at = condition.Position
+		}
+		for _, decision := range condition.decisions() {
+			decisionFunctions, err := actionFunctions(decisionName(&condition, decision), decision, condition.Namespace)
+			if err != nil {
+				return nil, err
+			}
+			functions = append(functions, decisionFunctions...)
+		}
+		for i := first; i < len(functions); i++ {
+			functions[i].condition, functions[i].file = condition.Name, condition.File
+		}
+	}
+	for _, handler := range r.allErrorHandlers() {
+		decisionFunctions, err := actionFunctions(handler.decision.Name, handler.decision, handler.namespace)
+		if err != nil {
+			return nil, err
+		}
+		for i := range decisionFunctions {
+			decisionFunctions[i].file = handler.file
+		}
+		functions = append(functions, decisionFunctions...)
+	}
+	sort.Slice(functions, func(i, j int) bool { return functions[i].key < functions[j].key })
+	return functions, nil
+}
+
+// actionFunctions returns the action function of a decision and the function applying its `set` assignments, if any
+func actionFunctions(key string, decision *Decision, namespace string) ([]ruleFunction, error) {
+	functions := []ruleFunction{}
+	if decision.Action != "" {
+		functions = append(functions, ruleFunction{key: key, code: decision.Action, isAction: true, namespace: namespace, source: decision.source, at: decision.Position})
+	}
+	if len(decision.Set) > 0 {
+		code, err := setFunction(decision.Set, decision.params()...)
+		if err != nil {
+			return nil, fmt.Errorf("error in set of %s: %w", key, err)
+		}
+		functions = append(functions, ruleFunction{key: key + setSuffix, code: code, isAction: true, namespace: namespace, at: decision.Position})
+	}
+	return functions, nil
+}
+
+// decisionName returns the name of a decision, which defaults to `<condition>_<value>`
+func decisionName(condition *Condition, decision *Decision) string {
+	if decision.Name != "" {
+		return decision.Name
+	}
+	return fmt.Sprintf("%s_%t", condition.Name, decision.Value)
+}
+
+// parseFunction parses a check or action function and returns its name, or an empty name for anonymous functions.
+// The function is parsed as an expression, so it never declares anything in the global scope.
+func parseFunction(key, funcCode string) (*ast.Program, string, error) {
+	program, err := parser.ParseFile(nil, key, "("+funcCode+"\n)", 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := ""
+	if len(program.Body) == 1 {
+		if statement, ok := program.Body[0].(*ast.ExpressionStatement); ok {
+			if function, ok := statement.Expression.(*ast.FunctionLiteral); ok && function.Name != nil {
+				name = string(function.Name.Name)
+			}
+		}
+	}
+
+	return program, name, nil
+}
+
+// scriptDeclarations returns the names declared in the global scope by scripts
+func scriptDeclarations(program *ast.Program) []string {
+	names := []string{}
+	for _, statement := range program.Body {
+		var bindings []*ast.Binding
+		switch s := statement.(type) {
+		case *ast.FunctionDeclaration:
+			if s.Function.Name != nil {
+				names = append(names, string(s.Function.Name.Name))
+			}
+		case *ast.ClassDeclaration:
+			if s.Class.Name != nil {
+				names = append(names, string(s.Class.Name.Name))
+			}
+		case *ast.VariableStatement:
+			bindings = s.List
+		case *ast.LexicalDeclaration:
+			bindings = s.List
+		}
+		for _, binding := range bindings {
+			if identifier, ok := binding.Target.(*ast.Identifier); ok {
+				names = append(names, string(identifier.Name))
+			}
+		}
+	}
+	return names
+}
+
+// keyCollisions reports check and action functions sharing a registry key, such as a condition named after
+// the default name of a decision of another condition, which would overwrite each other in the registry
+func keyCollisions(functions []ruleFunction) []ValidationIssue {
+	owners := map[string][]string{}
+	files := map[string]string{}
+	for _, function := range functions {
+		owner := "condition " + function.condition
+		if function.condition == "" {
+			owner = "on_error"
+		}
+		switch {
+		case !function.isAction:
+			owner = "check of " + owner
+		case strings.HasSuffix(function.key, setSuffix):
+			owner = "set of " + owner
+		default:
+			owner = "action of " + owner
+		}
+		owners[function.key] = append(owners[function.key], owner)
+		if _, ok := files[function.key]; !ok {
+			files[function.key] = function.file
+		}
+	}
+
+	issues := []ValidationIssue{}
+	for key, keyOwners := range owners {
+		if len(keyOwners) > 1 {
+			sort.Strings(keyOwners)
+			issues = append(issues, ValidationIssue{File: files[key],
+				Message: fmt.Sprintf("function key '%s' is used by %s", key, strings.Join(keyOwners, ", "))})
+		}
+	}
+	sortIssues(issues)
+	return issues
+}
+
+// nameCollisions reports named check and action functions shadowing a scripts declaration
+// and scripts declaring a name that is reserved by the engine.
+// Functions sharing a name don't collide, as they are registered by key.
+func nameCollisions(file string, functionNames map[string][]string, declared map[string]bool, reserved []string) []ValidationIssue {
+	issues := []ValidationIssue{}
+
+	for name, keys := range functionNames {
+		if declared[name] {
+			issues = append(issues, ValidationIssue{File: file, Condition: keys[0], Message: fmt.Sprintf("function name '%s' shadows a scripts declaration", name)})
+		}
+	}
+
+	for _, name := range reserved {
+		if declared[name] {
+			issues = append(issues, ValidationIssue{File: file, Message: fmt.Sprintf("scripts declare '%s' which is reserved by the engine", name)})
+		}
+	}
+
+	sortIssues(issues)
+	return issues
+}
+
+// compiledFunction is a check or action function compiled once at runner construction
+type compiledFunction struct {
+	key string
+	// name of the javascript function, or the key for anonymous functions
+	name     string
+	program  *goja.Program
+	isAction bool
+}
+
+// compiledScope is the scripts and functions of a namespaced rule set evaluated in their own scope.
+// The program evaluates to an object holding the exported script functions and the check and action functions.
+type compiledScope struct {
+	namespace string
+	file      string
+	program   *goja.Program
+	functions []compiledFunction
+}
+
+// compiledRules holds the scripts and functions of the rules compiled once when the runner is constructed
+type compiledRules struct {
+	scripts   *goja.Program
+	functions []compiledFunction
+	scopes    []compiledScope
+	// scripts declare let, const or class bindings in the global scope, which a runtime can't declare twice
+	lexicalScripts bool
+	// rules the scripts come from, for error reporting
+	rules *Rules
+}
+
+// compileRules compiles the scripts and functions of the rules and detects key and name collisions.
+// reserved lists the global names provided by the engine, such as `context` and go functions.
+func compileRules(rules *Rules, reserved []string) (*compiledRules, error) {
+	compiled := &compiledRules{rules: rules}
+	issues := []ValidationIssue{}
+
+	ruleFunctions, err := rules.functions()
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, keyCollisions(ruleFunctions)...)
+	namespaces := map[string][]ruleFunction{}
+	for _, function := range ruleFunctions {
+		namespaces[function.namespace] = append(namespaces[function.namespace], function)
+	}
+
+	declared := map[string]bool{}
+	if rules.Scripts != "" {
+		program, err := parser.ParseFile(nil, rules.Name, rules.Scripts, 0)
+		if err == nil {
+			compiled.scripts, err = goja.CompileAST(program, false)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error compiling scripts: %w", rules.locateError(newScriptError(err, rules.File, "", ""), "", Position{}, 0, Position{}))
+		}
+		for _, name := range scriptDeclarations(program) {
+			declared[name] = true
+		}
+		for _, statement := range program.Body {
+			switch statement.(type) {
+			case *ast.LexicalDeclaration, *ast.ClassDeclaration:
+				compiled.lexicalScripts = true
+			}
+		}
+	}
+
+	functions, functionNames, err := compileFunctions(rules, namespaces[""])
+	if err != nil {
+		return nil, err
+	}
+	compiled.functions = functions
+
+	for _, source := range rules.scriptSources() {
+		if source.namespace == "" {
+			continue
+		}
+		reserved = append(reserved, source.namespace)
+
+		scope, scopeIssues, err := compileScope(rules, source, namespaces[source.namespace])
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, scopeIssues...)
+		compiled.scopes = append(compiled.scopes, *scope)
+	}
+
+	issues = append(issues, nameCollisions(rules.File, functionNames, declared, reserved)...)
+	if len(issues) > 0 {
+		sortIssues(issues)
+		return nil, &ValidationError{Issues: issues}
+	}
+
+	return compiled, nil
+}
+
+// compileFunctions compiles check and action functions and maps the names of named functions to their keys
+func compileFunctions(rules *Rules, functions []ruleFunction) ([]compiledFunction, map[string][]string, error) {
+	compiled := make([]compiledFunction, 0, len(functions))
+	functionNames := map[string][]string{}
+	for _, function := range functions {
+		var compiledProgram *goja.Program
+		program, name, err := parseFunction(function.key, function.code)
+		if err == nil {
+			compiledProgram, err = goja.CompileAST(program, false)
+		}
+		if err != nil {
+			if function.isAction {
+				scriptErr := rules.locateError(newScriptError(err, function.file, function.condition, function.key), function.key, function.source, function.prefix, function.at)
+				return nil, nil, fmt.Errorf("error compiling action function %s: %w", function.key, scriptErr)
+			}
+			scriptErr := rules.locateError(newScriptError(err, function.file, function.condition, ""), function.key, function.source, function.prefix, function.at)
+			return nil, nil, fmt.Errorf("error compiling condition function %s: %w", function.key, scriptErr)
+		}
+
+		compiled = append(compiled, compiledFunction{
+			key:      function.key,
+			name:     ifEmpty(name, function.key),
+			program:  compiledProgram,
+			isAction: function.isAction,
+		})
+
+		if name != "" {
+			functionNames[name] = append(functionNames[name], function.key)
+		}
+	}
+	return compiled, functionNames, nil
+}
+
+// compileScope compiles the scripts and functions of a namespaced rule set into a single program
+// so the functions see the scripts of their own rule set while the scripts don't declare anything globally
+func compileScope(rules *Rules, source scriptSource, functions []ruleFunction) (*compiledScope, []ValidationIssue, error) {
+	program, err := parser.ParseFile(nil, source.file, source.scripts, 0)
+	if err != nil {
+		scriptErr := newScriptError(err, source.file, "", "")
+		if scriptErr.Line > 0 && source.at.IsValid() {
+			scriptErr.Position = source.at.offset(scriptErr.Line, scriptErr.Column)
+		}
+		return nil, nil, fmt.Errorf("error compiling scripts of %s: %w", source.namespace, scriptErr)
+	}
+	declared := map[string]bool{}
+	for _, name := range scriptDeclarations(program) {
+		declared[name] = true
+	}
+
+	compiledFunctions, functionNames, err := compileFunctions(rules, functions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	issues := nameCollisions(source.file, functionNames, declared, nil)
+
+	var code strings.Builder
+	code.WriteString("(function() {\n")
+	code.WriteString(source.scripts)
+	code.WriteString("\n;return { exports: {")
+	for _, name := range source.exports {
+		if !declared[name] {
+			issues = append(issues, ValidationIssue{File: source.file, Message: fmt.Sprintf("exported function '%s' is not declared in scripts", name)})
+			continue
+		}
+		fmt.Fprintf(&code, "%q: %s, ", name, name)
+	}
+	code.WriteString("}, functions: [\n")
+	for _, function := range functions {
+		code.WriteString("(" + function.code + "\n),\n")
+	}
+	code.WriteString("] };\n})()")
+
+	scope := &compiledScope{namespace: source.namespace, file: source.file, functions: compiledFunctions}
+	if scope.program, err = goja.Compile(source.file, code.String(), false); err != nil {
+		return nil, nil, fmt.Errorf("error compiling scripts of %s: %w", source.namespace, rules.locateError(newScriptError(err, source.file, "", ""), "", Position{}, 0, Position{}))
+	}
+
+	return scope, issues, nil
+}
+
+// locateError sets the position in the rules file of an error raised while running the scripts
+func (c *compiledRules) locateError(scriptErr *ScriptError) *ScriptError {
+	return c.rules.locateError(scriptErr, "", Position{}, 0, Position{})
+}
+
+// registeredFunction is a check or action function evaluated in a runtime
+type registeredFunction struct {
+	// name of the javascript function, or the condition or decision name for anonymous functions
+	name string
+	fn   goja.Callable
+}
+
+// registry holds the check and action functions of a run keyed by condition or decision name.
+// The functions are owned by the engine and never declared in the global scope,
+// so functions sharing a name don't overwrite each other or the scripts.
+type registry map[string]registeredFunction
+
+// load runs the scripts in the vm and evaluates the functions into a registry
+func (c *compiledRules) load(vm *goja.Runtime) (registry, error) {
+	if c.scripts != nil {
+		if _, err := vm.RunProgram(c.scripts); err != nil {
+			return nil, fmt.Errorf("error injecting scripts into vm: %w", c.locateError(newScriptError(err, c.rules.File, "", "")))
+		}
+	}
+
+	functions := make(registry, len(c.functions))
+	for _, function := range c.functions {
+		value, err := vm.RunProgram(function.program)
+		if err != nil {
+			return nil, fmt.Errorf("error injecting function %s into vm: %w", function.name, err)
+		}
+		fn, ok := goja.AssertFunction(value)
+		if !ok {
+			if function.isAction {
+				return nil, fmt.Errorf("action of %s is not a function", function.key)
+			}
+			return nil, fmt.Errorf("check of condition %s is not a function", function.key)
+		}
+		functions[function.key] = registeredFunction{name: function.name, fn: fn}
+	}
+
+	for _, scope := range c.scopes {
+		value, err := vm.RunProgram(scope.program)
+		if err != nil {
+			return nil, fmt.Errorf("error injecting scripts of %s into vm: %w", scope.namespace, c.locateError(newScriptError(err, scope.file, "", "")))
+		}
+		object := value.ToObject(vm)
+		if err := vm.Set(scope.namespace, object.Get("exports")); err != nil {
+			return nil, fmt.Errorf("error injecting scripts of %s into vm: %w", scope.namespace, err)
+		}
+
+		values := object.Get("functions").ToObject(vm)
+		for i, function := range scope.functions {
+			fn, ok := goja.AssertFunction(values.Get(strconv.Itoa(i)))
+			if !ok {
+				if function.isAction {
+					return nil, fmt.Errorf("action of %s is not a function", function.key)
+				}
+				return nil, fmt.Errorf("check of condition %s is not a function", function.key)
+			}
+			functions[function.key] = registeredFunction{name: function.name, fn: fn}
+		}
+	}
+
+	return functions, nil
+}
@@ -0,0 +1,260 @@
+package yabre
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCompileRules_ScriptCompilationErrors(t *testing.T) {
+	rules := &Rules{
+		Scripts: "invalid javascript {{{",
+	}
+
+	_, err := compileRules(rules, nil)
+
+	if err == nil {
+		t.Fatal("Expected error for invalid JavaScript")
+	}
+	if !strings.Contains(err.Error(), "error compiling scripts") {
+		t.Errorf("Expected script compilation error, got: %v", err)
+	}
+}
+
+func TestCompileRules_InvalidJavaScriptSyntax(t *testing.T) {
+	rules := &Rules{
+		Conditions: map[string]Condition{
+			"test": {
+				Name:  "test",
+				Check: "function check() { return", // Invalid JS
+			},
+		},
+	}
+
+	_, err := compileRules(rules, nil)
+
+	if err == nil {
+		t.Fatal("Expected error for invalid JavaScript function")
+	}
+	if !strings.Contains(err.Error(), "error compiling condition function test") {
+		t.Errorf("Expected condition function compilation error, got: %v", err)
+	}
+}
+
+func TestCompileRules_MissingCheckFunctions(t *testing.T) {
+	rules := &Rules{
+		Conditions: map[string]Condition{
+			"test": {
+				Name: "test",
+				// No check function
+				True: &Decision{
+					Name:   "test_true",
+					Action: "function() { return true; }",
+				},
+			},
+		},
+	}
+
+	compiled, err := compileRules(rules, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	functions, err := compiled.load(goja.New())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Verify no function was registered for empty check
+	if _, exists := functions["test"]; exists {
+		t.Error("Expected no function for empty check")
+	}
+	if _, exists := functions["test_true"]; !exists {
+		t.Error("Expected action function to be registered")
+	}
+}
+
+func TestParseFunction_VariousFunctionNamePatterns(t *testing.T) {
+	tests := []struct {
+		name         string
+		funcCode     string
+		expectedName string
+	}{
+		{
+			name:         "Named function",
+			funcCode:     "function myFunc() { return true; }",
+			expectedName: "myFunc",
+		},
+		{
+			name:         "Named function with spaces",
+			funcCode:     "function    spacedFunc   () { return true; }",
+			expectedName: "spacedFunc",
+		},
+		{
+			name:         "Function with parameters",
+			funcCode:     "function withParams(a, b) { return a + b; }",
+			expectedName: "withParams",
+		},
+		{
+			name:         "Named function with comment mentioning another function",
+			funcCode:     "function outer() { /* function inner() */ return true; }",
+			expectedName: "outer",
+		},
+		{
+			name:         "Anonymous function calling a named one",
+			funcCode:     "function() { return function helper() { return true; }(); }",
+			expectedName: "",
+		},
+		{
+			name:         "Arrow function",
+			funcCode:     "() => { return true; }",
+			expectedName: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, name, err := parseFunction("default", tt.funcCode)
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if name != tt.expectedName {
+				t.Errorf("Expected function name '%s', got: '%s'", tt.expectedName, name)
+			}
+		})
+	}
+}
+
+func TestParseFunction_InvalidFunctionCode(t *testing.T) {
+	_, _, err := parseFunction("invalid", "function broken() { return")
+
+	if err == nil {
+		t.Fatal("Expected error for invalid function code")
+	}
+}
+
+func TestCompiledRules_FunctionsDontPolluteGlobals(t *testing.T) {
+	yamlData := `
+name: "registry"
+scripts: |
+  function helper() { return "helper"; }
+conditions:
+  first:
+    check: "function check() { return 1; }"
+    true:
+      action: "function action() { context.first = helper(); }"
+  second:
+    check: "function check() { return 2; }"
+    true:
+      action: "() => { context.second = helper(); }"
+`
+	var rules Rules
+	if err := yaml.Unmarshal([]byte(yamlData), &rules); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// functions sharing a name are registered by key, so both conditions keep their own function
+	compiled, err := compileRules(&rules, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	vm := goja.New()
+	functions, err := compiled.load(vm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for key, expected := range map[string]int64{"first": 1, "second": 2} {
+		result, err := functions[key].fn(goja.Undefined())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.ToInteger() != expected {
+			t.Errorf("Expected %s to return %d, got: %v", key, expected, result)
+		}
+	}
+
+	if functions["first"].name != "check" || functions["second"].name != "check" || functions["second_true"].name != "second_true" {
+		t.Errorf("Unexpected function names: %s, %s", functions["first"].name, functions["second"].name)
+	}
+
+	// functions are not declared in the global scope
+	for _, name := range []string{"check", "action", "first", "first_true", "second_true"} {
+		if value := vm.Get(name); value != nil && value != goja.Undefined() {
+			t.Errorf("Expected '%s' not to be a global", name)
+		}
+	}
+}
+
+func TestCompileRules_NameCollisions(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		reserved []string
+		expected string
+	}{
+		{
+			name: "Function shadows scripts helper",
+			yaml: `
+scripts: |
+  function helper() { return true; }
+conditions:
+  first:
+    check: "function helper() { return helper(); }"
+`,
+			expected: "condition 'first': function name 'helper' shadows a scripts declaration",
+		},
+		{
+			name: "Condition named after a decision",
+			yaml: `
+conditions:
+  a:
+    check: "function check() { return true; }"
+    true:
+      action: "function() { context.a = true; }"
+      set:
+        done: true
+  a_true:
+    check: "function check() { return false; }"
+  a_true.set:
+    check: "function() { return false; }"
+`,
+			expected: "function key 'a_true' is used by action of condition a, check of condition a_true; " +
+				"function key 'a_true.set' is used by check of condition a_true.set, set of condition a",
+		},
+		{
+			name: "Scripts declare engine global",
+			yaml: `
+scripts: |
+  var context = {};
+  const add = (a, b) => a + b;
+conditions:
+  first:
+    check: "function() { return true; }"
+`,
+			reserved: []string{"add", "context"},
+			expected: "scripts declare 'add' which is reserved by the engine; scripts declare 'context' which is reserved by the engine",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules Rules
+			if err := yaml.Unmarshal([]byte(tt.yaml), &rules); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			_, err := compileRules(&rules, tt.reserved)
+			if err == nil {
+				t.Fatal("Expected name collision error")
+			}
+			if !strings.HasSuffix(err.Error(), tt.expected) {
+				t.Errorf("Expected error ending with '%s', got: %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package yabre
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reload scans the files of the library again and replaces the rule sets of the library with them
+// if they all load and ValidateAll finds no issues other than warnings, which ValidateAll keeps reporting.
+// Runners created afterwards use the new rule sets, runners created before keep theirs.
+// On error the library keeps its current rule sets.
+func (rl *RulesLibrary) Reload() error {
+	files, err := rl.readFiles(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to scan files: %w", err)
+	}
+	return rl.reload(files)
+}
+
+// reload validates the rule sets of the files and swaps them in
+func (rl *RulesLibrary) reload(files map[string][]byte) error {
+	rl.reloading.Lock()
+	defer rl.reloading.Unlock()
+
+	scanned := &RulesLibrary{source: rl.source, signatureKeys: rl.signatureKeys}
+	if err := scanned.scanFiles(files); err != nil {
+		return fmt.Errorf("failed to scan files: %w", err)
+	}
+	// warnings don't prevent the rules from loading or running, so they don't prevent reloading them either
+	if err := scanned.validateAll(); err != nil {
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			return fmt.Errorf("failed to validate rules: %w", err)
+		}
+		if issues := validationErr.blocking(); len(issues) > 0 {
+			return fmt.Errorf("failed to validate rules: %w", &ValidationError{Issues: issues})
+		}
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.rulePaths, rl.dependencies, rl.versions, rl.files = scanned.rulePaths, scanned.dependencies, scanned.versions, scanned.files
+	return nil
+}
+
+// Watch reloads the library when its files have changed, until ctx is done. Sources notifying changes, like MemorySource,
+// are read when they signal a change, other sources are polled every interval and reloaded once two polls in a row read the
+// same files, so files being written aren't loaded partially.
+// onReload, if not nil, is called after every reload with its error, nil if the new rule sets have been swapped in.
+// Files that failed to reload aren't reloaded again until they change.
+func (rl *RulesLibrary) Watch(ctx context.Context, interval time.Duration, onReload func(err error)) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid watch interval: %v", interval)
+	}
+
+	changes, err := rl.source.Watch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to watch rule source: %w", err)
+	}
+	var poll <-chan time.Time
+	if changes == nil {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	var failed, previous map[string][]byte
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-poll:
+		case <-changes:
+		}
+
+		files, err := rl.readFiles(ctx)
+		if err != nil && ctx.Err() != nil {
+			return nil
+		}
+		// polled files may be partially written, so they are reloaded once two polls in a row read the same files
+		stable := poll == nil || sameFiles(files, previous)
+		previous = files
+		if err != nil {
+			err = fmt.Errorf("failed to scan files: %w", err)
+		} else if sameFiles(files, rl.current().files) || sameFiles(files, failed) || !stable {
+			continue
+		} else if err = rl.reload(files); err != nil {
+			failed = files
+		}
+		if onReload != nil {
+			onReload(err)
+		}
+	}
+}
+
+// sameFiles reports whether two sets of rule files have the same paths and contents
+func sameFiles(a, b map[string][]byte) bool {
+	if a == nil || b == nil || len(a) != len(b) {
+		return false
+	}
+	for path, data := range a {
+		other, ok := b[path]
+		if !ok || !bytes.Equal(data, other) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package yabre
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pricingRules(rate string) string {
+	return `
+name: pricing
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      set:
+        Rate: ` + rate + `
+      terminate: true
+`
+}
+
+type ReloadContext struct {
+	Rate float64
+}
+
+func runPricing(t *testing.T, rl *RulesLibrary) float64 {
+	rulesContext := ReloadContext{}
+	runner, err := NewRulesRunnerFromLibrary(rl, "pricing", &rulesContext)
+	require.NoError(t, err)
+	result, err := runner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+	return result.Rate
+}
+
+func TestRulesLibrary_Reload(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "pricing.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(pricingRules("1")), 0644))
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: tempDir})
+	require.NoError(t, err)
+	oldRunner, err := NewRulesRunnerFromLibrary(rl, "pricing", &ReloadContext{})
+	require.NoError(t, err)
+
+	// edited files are only picked up by a reload
+	require.NoError(t, os.WriteFile(path, []byte(pricingRules("2")), 0644))
+	assert.Equal(t, 1.0, runPricing(t, rl))
+
+	require.NoError(t, rl.Reload())
+	assert.Equal(t, 2.0, runPricing(t, rl))
+
+	// runners created before the reload keep their rules
+	rulesContext := ReloadContext{}
+	result, err := oldRunner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, result.Rate)
+
+	// invalid rules are rejected and the last good version is kept
+	require.NoError(t, os.WriteFile(path, []byte(pricingRules("2")+"    false:\n      next: missing\n"), 0644))
+	err = rl.Reload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to validate rules")
+	assert.Equal(t, 2.0, runPricing(t, rl))
+
+	require.NoError(t, os.WriteFile(path, []byte("name: [pricing"), 0644))
+	err = rl.Reload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse")
+	assert.Equal(t, 2.0, runPricing(t, rl))
+	assert.Equal(t, map[string]string{"pricing": "pricing.yaml"}, rl.GetRuleNamesAndPaths())
+}
+
+func TestRulesLibrary_ReloadWithWarnings(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "pricing.yaml")
+	// a condition name violating the naming convention and an unused condition are only warnings
+	rules := func(rate string) string {
+		return `
+name: pricing
+conditions:
+  CheckWeight:
+    default: true
+    expr: "true"
+    true:
+      set:
+        Rate: ` + rate + `
+      terminate: true
+  unused:
+    expr: "true"
+    true:
+      terminate: true
+`
+	}
+	require.NoError(t, os.WriteFile(path, []byte(rules("1")), 0644))
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: tempDir})
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, runPricing(t, rl))
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(rl.ValidateAll(), &validationErr))
+	require.Len(t, validationErr.Issues, 2)
+	assert.True(t, validationErr.Issues[0].Warning)
+	assert.True(t, validationErr.Issues[1].Warning)
+
+	require.NoError(t, rl.Reload())
+	require.NoError(t, os.WriteFile(path, []byte(rules("2")), 0644))
+	require.NoError(t, rl.Reload())
+	assert.Equal(t, 2.0, runPricing(t, rl))
+
+	// errors along with warnings still reject the reload and are the only issues reported
+	require.NoError(t, os.WriteFile(path, []byte(rules("3")+"    false:\n      next: missing\n"), 0644))
+	err = rl.Reload()
+	require.True(t, errors.As(err, &validationErr))
+	require.Len(t, validationErr.Issues, 1)
+	assert.Equal(t, "next condition 'missing' of false branch not found", validationErr.Issues[0].Message)
+	assert.Equal(t, 2.0, runPricing(t, rl))
+}
+
+func TestRulesLibrary_Watch(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "pricing.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(pricingRules("1")), 0644))
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: tempDir})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan error, 10)
+	watching := make(chan error)
+	go func() {
+		watching <- rl.Watch(ctx, 20*time.Millisecond, func(err error) { reloads <- err })
+	}()
+
+	waitReload := func() error {
+		select {
+		case err := <-reloads:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("library not reloaded")
+			return nil
+		}
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte(pricingRules("3")), 0644))
+	require.NoError(t, waitReload())
+	assert.Equal(t, 3.0, runPricing(t, rl))
+
+	require.NoError(t, os.WriteFile(path, []byte("name: [pricing"), 0644))
+	assert.Error(t, waitReload())
+	assert.Equal(t, 3.0, runPricing(t, rl))
+
+	// a file that failed to reload isn't reloaded again until it changes
+	select {
+	case err := <-reloads:
+		t.Fatalf("unexpected reload: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte(pricingRules("4")), 0644))
+	require.NoError(t, waitReload())
+	assert.Equal(t, 4.0, runPricing(t, rl))
+
+	cancel()
+	assert.NoError(t, <-watching)
+	assert.Error(t, rl.Watch(context.Background(), 0, nil))
+}
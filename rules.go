@@ -3,18 +3,111 @@ package yabre
 import (
 	"errors"
 	"fmt"
-	"regexp"
+	"sort"
 
-	"github.com/dop251/goja"
 	"gopkg.in/yaml.v2"
 )
 
 type Rules struct {
-	Name             string               `yaml:"name"`
+	Name string `yaml:"name"`
+	// Version of the rule set, e.g. `1.2.0`; a library can hold several versions of a rule set
+	Version string `yaml:"version,omitempty"`
+	// Require lists the rule sets merged into this one, as `<rule set>` for their latest version
+	// or `<rule set>@<version constraint>`, e.g. `rates@^1.2`
 	Require          []string             `yaml:"require,omitempty"`
 	Scripts          string               `yaml:"scripts"`
 	Conditions       map[string]Condition `yaml:"conditions"`
 	DefaultCondition *Condition           `yaml:"-"`
+	// Namespaced rule sets keep their conditions and scripts to themselves when required by other rule sets:
+	// their conditions are referenced as `<rule set>.<condition>` and their scripts are evaluated in their own scope
+	Namespaced bool `yaml:"namespaced,omitempty"`
+	// Exports lists the script functions of a namespaced rule set available to other rule sets as `<rule set>.<function>`
+	Exports []string `yaml:"exports,omitempty"`
+	// Tables are the decision tables conditions can evaluate, keyed by name
+	Tables map[string]*DecisionTable `yaml:"tables,omitempty"`
+	// OnError is the decision taken when a condition without its own on_error decision fails
+	OnError *Decision `yaml:"on_error,omitempty"`
+	// Mode selects how conditions are run, ModeFlow by default
+	Mode ExecutionMode `yaml:"mode,omitempty"`
+	// File is the path of the file the rules were loaded from, if loaded from a library
+	File string `yaml:"-"`
+	// Hash identifies the content of the rules files, those of the merged dependencies included, as `sha256:<hex>`
+	Hash string `yaml:"-"`
+	// scripts of the rules and of their merged dependencies along with the files they come from
+	sources []scriptSource
+	// position of the scripts in the rules file
+	scriptsAt Position
+	// maps the names of the rule set and of its merged dependencies to their default conditions
+	entries map[string]string
+	// on_error decisions of the merged dependencies, by rule set name
+	errorHandlers map[string]ruleSetErrorHandler
+}
+
+// rulesErrorHandler is the name of the on_error decision of the rules
+const rulesErrorHandler = "on_error"
+
+// ruleSetErrorHandler is the on_error decision of a rule set, taken when its conditions without their own on_error fail
+type ruleSetErrorHandler struct {
+	decision *Decision
+	// namespace of the rule set, if it is namespaced, and file the decision comes from
+	namespace string
+	file      string
+}
+
+// errorHandler returns the on_error decision taken when a condition of the named rule set without its own on_error fails:
+// the on_error of the rule set if it has been merged into the rules with one, otherwise the on_error of the rules
+func (r *Rules) errorHandler(ruleSet string) *Decision {
+	if handler, ok := r.errorHandlers[ruleSet]; ok {
+		return handler.decision
+	}
+	return r.OnError
+}
+
+// allErrorHandlers returns the on_error decisions of the rules and of their merged dependencies
+func (r *Rules) allErrorHandlers() []ruleSetErrorHandler {
+	handlers := []ruleSetErrorHandler{}
+	if r.OnError != nil {
+		handlers = append(handlers, ruleSetErrorHandler{decision: r.OnError, file: r.File})
+	}
+	ruleSets := make([]string, 0, len(r.errorHandlers))
+	for ruleSet := range r.errorHandlers {
+		ruleSets = append(ruleSets, ruleSet)
+	}
+	sort.Strings(ruleSets)
+	for _, ruleSet := range ruleSets {
+		handlers = append(handlers, r.errorHandlers[ruleSet])
+	}
+	return handlers
+}
+
+// entry returns the name of the default condition of the named rule set, which can be called by decisions
+func (r *Rules) entry(ruleSet string) (string, bool) {
+	if name, ok := r.entries[ruleSet]; ok {
+		return name, true
+	}
+	if ruleSet == r.Name && r.DefaultCondition != nil {
+		return r.DefaultCondition.Name, true
+	}
+	return "", false
+}
+
+// scriptSource is a scripts section of a single rules file
+type scriptSource struct {
+	file    string
+	scripts string
+	// namespace of a namespaced rule set, whose scripts are evaluated in their own scope
+	namespace string
+	exports   []string
+	// position of the scripts in the file
+	at Position
+}
+
+// scriptSources returns the scripts sections the rules were merged from
+func (r *Rules) scriptSources() []scriptSource {
+	if len(r.sources) == 0 && r.Scripts != "" {
+		return []scriptSource{{file: r.File, scripts: r.Scripts, at: r.scriptsAt}}
+	}
+	return r.sources
 }
 
 // Perform enrichment and validation of rules data during unmarshalling
@@ -25,6 +118,23 @@ func (r *Rules) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	if rr.Mode != "" && rr.Mode != ModeFlow && rr.Mode != ModeAgenda {
+		return fmt.Errorf("unknown mode %s", rr.Mode)
+	}
+
+	if rr.Version != "" {
+		if _, err := parseVersion(rr.Version); err != nil {
+			return err
+		}
+	}
+	for _, reference := range rr.Require {
+		if _, constraint := splitRuleSetReference(reference); constraint != "" {
+			if _, err := parseVersionConstraint(constraint); err != nil {
+				return fmt.Errorf("invalid require %s: %w", reference, err)
+			}
+		}
+	}
+
 	defaultFound := false
 
 	// add names to conditions
@@ -32,15 +142,16 @@ func (r *Rules) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	for name, condition := range rr.Conditions {
 		condition.Name = name
 
-		if condition.True != nil {
-			condition.True.Name = condition.Name + "_true"
-			condition.True.Value = true
+		if condition.isSwitch() && (condition.True != nil || condition.False != nil) {
+			return fmt.Errorf("condition %s can't have both cases and true/false branches", name)
 		}
-
-		if condition.False != nil {
-			condition.False.Name = condition.Name + "_false"
-			condition.False.Value = false
+		if condition.Check != "" && condition.Expr != "" {
+			return fmt.Errorf("condition %s can't have both check and expr", name)
+		}
+		if condition.Table != "" && (condition.Check != "" || condition.Expr != "") {
+			return fmt.Errorf("condition %s can't have a table along with check or expr", name)
 		}
+		condition.nameDecisions()
 
 		rr.Conditions[name] = condition
 
@@ -54,71 +165,31 @@ func (r *Rules) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		}
 	}
 
+	if rr.OnError != nil {
+		rr.OnError.Name = rulesErrorHandler
+		rr.OnError.errorHandler = true
+	}
+
+	for name, table := range rr.Tables {
+		if table == nil {
+			return fmt.Errorf("table %s is empty", name)
+		}
+		table.Name = name
+	}
+
 	*r = Rules(rr)
 	return nil
 }
 
-func (rr *RulesRunner[Context]) loadRulesFromYaml(yamlFile []byte) (*Rules, error) {
+func loadRulesFromYaml(yamlFile []byte) (*Rules, error) {
 	// Parse the YAML into a Rule struct
 	var rules Rules
 	err := yaml.Unmarshal(yamlFile, &rules)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing YAML: %v", err)
 	}
-
-	// Now unmarshal the same yaml into an ordered list to get the first condition
+	rules.locate("", yamlFile)
+	rules.Hash = contentHash(yamlFile)
 
 	return &rules, nil
 }
-
-func (runner *RulesRunner[Context]) addJsFunctions(vm *goja.Runtime) error {
-	// add all js functions to the vm
-	if runner.Rules.Scripts != "" {
-		_, err := vm.RunString(runner.Rules.Scripts)
-		if err != nil {
-			return fmt.Errorf("error injecting scripts into vm: %w", err)
-		}
-	}
-
-	for _, condition := range runner.Rules.Conditions {
-		if condition.Check != "" {
-			checkName := condition.Name
-			if err := runner.injectJSFunction(vm, checkName, condition.Check); err != nil {
-				return fmt.Errorf("error injecting condition function into vm: %w", err)
-			}
-		}
-		if condition.True != nil && condition.True.Action != "" {
-			actionName := fmt.Sprintf("%s_%t", condition.Name, condition.True.Value)
-			if err := runner.injectJSFunction(vm, actionName, condition.True.Action); err != nil {
-				return fmt.Errorf("error injecting action function into vm: %w", err)
-			}
-		}
-		if condition.False != nil && condition.False.Action != "" {
-			actionName := fmt.Sprintf("%s_%t", condition.Name, condition.False.Value)
-			if err := runner.injectJSFunction(vm, actionName, condition.False.Action); err != nil {
-				return fmt.Errorf("error injecting action function into vm: %w", err)
-			}
-		}
-	}
-
-	return nil
-}
-
-var funcNameRegex = regexp.MustCompile(`function\s+(\w+)\s*\(`)
-
-func (runner *RulesRunner[Context]) injectJSFunction(vm *goja.Runtime, defaultName, funcCode string) error {
-	funcName := defaultName
-
-	matches := funcNameRegex.FindStringSubmatch(funcCode)
-	if len(matches) > 1 {
-		funcName = matches[1]
-	}
-
-	runner.functionNames[defaultName] = funcName // Store the function name mapping
-	_, err := vm.RunString(fmt.Sprintf("%s = %s", funcName, funcCode))
-	if err != nil {
-		return fmt.Errorf("error injecting function %s into vm: %w", funcName, err)
-	}
-
-	return nil
-}
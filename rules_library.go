@@ -1,24 +1,35 @@
 package yabre
 
 import (
-	"embed"
+	"context"
+	"crypto/ed25519"
 	"fmt"
 	"io/fs"
-	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v2"
 )
 
 type RulesLibrary struct {
-	// maps rule name to its file path
+	// guards the rule sets below, which are replaced as a whole when the library is reloaded
+	mu sync.RWMutex
+	// maps rule name to the file path of its latest version
 	rulePaths map[string]string
-	// maps rule name to its dependencies
+	// maps rule name to the dependencies of its latest version
 	dependencies map[string][]string
-	// File system for os or embedded file systems
-	fileSystem fs.FS
-	// base path for all rules/libraries
-	basePath string
+	// maps rule name to all its versions, ordered from the lowest to the latest
+	versions map[string][]ruleSetVersion
+	// contents of the rule files by path, as read when the files were scanned
+	files map[string][]byte
+	// source of the rule files
+	source RuleSource
+	// keys verifying the signatures of the rule files, if any
+	signatureKeys []ed25519.PublicKey
+	// serializes reloads
+	reloading sync.Mutex
 }
 
 type RulesLibrarySettings struct {
@@ -27,31 +38,26 @@ type RulesLibrarySettings struct {
 	BasePath string
 	// FileSystem specifies the file system to be used, either for the OS file system or an embedded file system.
 	FileSystem fs.FS
+	// Source, if not nil, provides the rule files instead of BasePath and FileSystem,
+	// e.g. a MemorySource, a SQLSource or a custom RuleSource reading them from an HTTP endpoint
+	Source RuleSource
+	// SignatureKeys, if not empty, make the library reject rule files without a valid detached ed25519 signature
+	// by one of the keys. The signature of a file is read from `<path>.sig`, raw or base64 encoded.
+	SignatureKeys []ed25519.PublicKey
 }
 
 func NewRulesLibrary(s RulesLibrarySettings) (*RulesLibrary, error) {
-	if s.BasePath == "" {
-		s.BasePath = "."
-	}
-
-	rl := &RulesLibrary{
-		rulePaths:    make(map[string]string),
-		dependencies: make(map[string][]string),
-		fileSystem:   s.FileSystem,
-		basePath:     s.BasePath,
-	}
-
-	if rl.fileSystem == nil {
-		rl.fileSystem = os.DirFS(rl.basePath)
-		// BasePath becomes relative to fileSystem root
-		rl.basePath = "."
-	} else if _, ok := rl.fileSystem.(embed.FS); ok {
-		// For embedded fs, directory walkthru fs.WalkDir doesn't like paths starting with ./ so we trim it
-		rl.basePath = strings.TrimPrefix(rl.basePath, "./")
+	rl := &RulesLibrary{source: s.Source, signatureKeys: s.SignatureKeys}
+	if rl.source == nil {
+		rl.source = newFSSource(s.FileSystem, s.BasePath)
 	}
 
 	// Scan all yaml files and map dependencies
-	if err := rl.scanFiles(); err != nil {
+	files, err := rl.readFiles(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan files: %w", err)
+	}
+	if err := rl.scanFiles(files); err != nil {
 		return nil, fmt.Errorf("failed to scan files: %w", err)
 	}
 
@@ -60,12 +66,54 @@ func NewRulesLibrary(s RulesLibrarySettings) (*RulesLibrary, error) {
 
 // GetRuleNamesAndPaths retrieves rule names and their associated paths for visualization purposes.
 func (rl *RulesLibrary) GetRuleNamesAndPaths() map[string]string {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
 	return rl.rulePaths
 }
 
+// current returns a library holding the current rule sets of rl, which aren't affected by reloads
+func (rl *RulesLibrary) current() *RulesLibrary {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return &RulesLibrary{
+		rulePaths:     rl.rulePaths,
+		dependencies:  rl.dependencies,
+		versions:      rl.versions,
+		files:         rl.files,
+		source:        rl.source,
+		signatureKeys: rl.signatureKeys,
+	}
+}
+
+// Versions returns the versions of the named rule set from the lowest to the latest, or nil if the rule set isn't versioned
+func (rl *RulesLibrary) Versions(name string) []string {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	versions := []string{}
+	for _, v := range rl.versions[name] {
+		if v.version != "" {
+			versions = append(versions, v.version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions
+}
+
+// LoadRules loads the named rule set merged with its dependencies.
+// The name can select a version of the rule set as `<rule set>@<version constraint>`, e.g. `pricing@1.2.0` or `pricing@^1`,
+// otherwise the latest version is loaded, which is the latest release unless the rule set only has pre-releases.
 func (rl *RulesLibrary) LoadRules(name string) (*Rules, error) {
+	return rl.current().loadRules(name)
+}
+
+func (rl *RulesLibrary) loadRules(reference string) (*Rules, error) {
+	name, _ := splitRuleSetReference(reference)
+
 	// Get ordered list of dependencies
-	deps, err := rl.resolveDependencies(name)
+	deps, ruleSet, err := rl.resolveDependencies(reference)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve dependencies: %w", err)
 	}
@@ -76,19 +124,26 @@ func (rl *RulesLibrary) LoadRules(name string) (*Rules, error) {
 	}
 
 	// Load and merge all dependencies first
-	for _, depName := range deps {
-		dep, err := rl.loadFile(rl.rulePaths[depName])
+	for _, depVersion := range deps {
+		depName := depVersion.name
+		dep, err := rl.loadFile(depVersion.path)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load dependency %s: %w", depName, err)
 		}
 
+		if dep.Namespaced {
+			if err := dep.qualify(); err != nil {
+				return nil, fmt.Errorf("failed to load dependency %s: %w", depName, err)
+			}
+		}
+
 		if err := rl.mergeRules(merged, dep); err != nil {
 			return nil, fmt.Errorf("failed to merge dependency %s: %w", depName, err)
 		}
 	}
 
 	// Finally load and merge the requested rule set
-	main, err := rl.loadFile(rl.rulePaths[name])
+	main, err := rl.loadFile(ruleSet.path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load rule set %s: %w", name, err)
 	}
@@ -97,46 +152,207 @@ func (rl *RulesLibrary) LoadRules(name string) (*Rules, error) {
 		return nil, fmt.Errorf("failed to merge rule set %s: %w", name, err)
 	}
 
+	if err := main.checkVisibility(); err != nil {
+		return nil, fmt.Errorf("failed to merge rule set %s: %w", name, err)
+	}
+
+	contents := make([][]byte, 0, len(deps)+1)
+	for _, dep := range deps {
+		contents = append(contents, rl.files[dep.path])
+	}
+	main.Hash = contentHash(append(contents, rl.files[ruleSet.path])...)
+
 	return main, nil
 }
 
-func (rl *RulesLibrary) resolveDependencies(name string) ([]string, error) {
-	visited := make(map[string]bool)
-	ordered := make([]string, 0)
+var namespaceRegex = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// qualify moves the conditions, tables and scripts of a namespaced rule set into its namespace:
+// conditions and tables are renamed to `<rule set>.<name>`, references to its own conditions and tables are qualified
+// and scripts are marked to be evaluated in their own scope
+func (r *Rules) qualify() error {
+	if !namespaceRegex.MatchString(r.Name) {
+		return fmt.Errorf("namespaced rule set name %s should be a valid javascript identifier", r.Name)
+	}
+
+	if r.OnError != nil {
+		if _, ok := r.Conditions[r.OnError.Next]; ok {
+			r.OnError.Next = r.Name + "." + r.OnError.Next
+		}
+	}
+
+	conditions := make(map[string]Condition, len(r.Conditions))
+	for name, condition := range r.Conditions {
+		condition.Name = r.Name + "." + name
+		condition.Namespace = r.Name
+		condition.nameDecisions()
+		if _, ok := r.Tables[condition.Table]; ok {
+			condition.Table = r.Name + "." + condition.Table
+		}
+		for _, decision := range condition.decisions() {
+			if _, ok := r.Conditions[decision.Next]; ok {
+				decision.Next = r.Name + "." + decision.Next
+			}
+		}
+		conditions[condition.Name] = condition
+	}
+	r.Conditions = conditions
+
+	tables := make(map[string]*DecisionTable, len(r.Tables))
+	for name, table := range r.Tables {
+		table.Name = r.Name + "." + name
+		tables[table.Name] = table
+	}
+	r.Tables = tables
+
+	if r.DefaultCondition != nil {
+		condition := r.Conditions[r.Name+"."+r.DefaultCondition.Name]
+		r.DefaultCondition = &condition
+		r.entries = map[string]string{r.Name: condition.Name}
+	}
+
+	r.sources = []scriptSource{{file: r.File, scripts: r.Scripts, namespace: r.Name, exports: r.Exports, at: r.scriptsAt}}
+	r.Scripts = ""
+
+	return nil
+}
+
+// checkVisibility makes sure no condition moves to a private condition of another rule set
+func (r *Rules) checkVisibility() error {
+	for _, condition := range r.Conditions {
+		for _, decision := range condition.decisions() {
+			if decision.Next == "" {
+				continue
+			}
+			next, ok := r.Conditions[decision.Next]
+			if ok && next.Private && next.RuleSet != condition.RuleSet {
+				return fmt.Errorf("condition %s of rule set %s can't move to private condition %s of rule set %s",
+					condition.Name, condition.RuleSet, next.Name, next.RuleSet)
+			}
+		}
+	}
+	return nil
+}
+
+// ruleSetVersion is a version of a rule set in the library
+type ruleSetVersion struct {
+	name string
+	// version is empty for rule sets without versions
+	version string
+	path    string
+	require []string
+}
+
+// reference returns the reference loading exactly this version of the rule set
+func (v ruleSetVersion) reference() string {
+	if v.version == "" {
+		return v.name
+	}
+	return v.name + "@=" + v.version
+}
+
+// selectVersion returns the latest version of the referenced rule set matching the version constraint of the reference
+func (rl *RulesLibrary) selectVersion(reference string) (ruleSetVersion, error) {
+	name, constraint := splitRuleSetReference(reference)
+	versions, exists := rl.versions[name]
+	if !exists {
+		return ruleSetVersion{}, fmt.Errorf("rule set %s not found", name)
+	}
+
+	latest := latestVersion(versions)
+	if constraint == "" || constraint == "latest" {
+		return latest, nil
+	}
+	if latest.version == "" {
+		return ruleSetVersion{}, fmt.Errorf("rule set %s has no versions, can't select %s", name, constraint)
+	}
+
+	matching, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return ruleSetVersion{}, err
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		v, _ := parseVersion(versions[i].version)
+		if matching.matches(v) {
+			return versions[i], nil
+		}
+	}
+	return ruleSetVersion{}, fmt.Errorf("rule set %s has no version matching %s", name, constraint)
+}
+
+// latestVersion returns the latest release of the versions ordered from the lowest, or their latest pre-release
+// if none of them is a release
+func latestVersion(versions []ruleSetVersion) ruleSetVersion {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if v, err := parseVersion(versions[i].version); err == nil && v.prerelease == "" {
+			return versions[i]
+		}
+	}
+	return versions[len(versions)-1]
+}
+
+// resolveDependencies returns the dependencies of the referenced rule set in load order, along with the rule set itself.
+// Every rule set is resolved to a single version, the latest one matching the first reference to it.
+// Rule sets requiring each other fail with a *DependencyCycleError.
+func (rl *RulesLibrary) resolveDependencies(reference string) ([]ruleSetVersion, ruleSetVersion, error) {
+	visited := make(map[string]ruleSetVersion)
+	ordered := make([]ruleSetVersion, 0)
+	// rule sets whose dependencies are being visited
+	path := []string{}
 
 	var visit func(string) error
-	visit = func(n string) error {
-		if visited[n] {
-			return nil
+	visit = func(ref string) error {
+		selected, err := rl.selectVersion(ref)
+		if err != nil {
+			return err
+		}
+
+		for i, name := range path {
+			if name == selected.name {
+				return &DependencyCycleError{Cycle: append(append([]string{}, path[i:]...), name)}
+			}
 		}
 
-		if _, exists := rl.rulePaths[n]; !exists {
-			return fmt.Errorf("rule set %s not found", n)
+		if resolved, ok := visited[selected.name]; ok {
+			if resolved.path == selected.path {
+				return nil
+			}
+			// a version resolved before is fine as long as it also matches this reference
+			if _, constraint := splitRuleSetReference(ref); constraint != "" {
+				matching, _ := parseVersionConstraint(constraint)
+				if v, _ := parseVersion(resolved.version); matching.matches(v) {
+					return nil
+				}
+			}
+			return fmt.Errorf("rule set %s is required in versions %s and %s", selected.name, resolved.version, selected.version)
 		}
 
-		visited[n] = true
+		visited[selected.name] = selected
 
 		// Visit all dependencies first
-		for _, dep := range rl.dependencies[n] {
+		path = append(path, selected.name)
+		for _, dep := range selected.require {
 			if err := visit(dep); err != nil {
 				return err
 			}
 		}
+		path = path[:len(path)-1]
 
-		ordered = append(ordered, n)
+		ordered = append(ordered, selected)
 		return nil
 	}
 
-	if err := visit(name); err != nil {
-		return nil, err
+	if err := visit(reference); err != nil {
+		return nil, ruleSetVersion{}, err
 	}
 
-	// Remove the last element as it's the main rule set
-	return ordered[:len(ordered)-1], nil
+	// The last element is the main rule set
+	return ordered[:len(ordered)-1], ordered[len(ordered)-1], nil
 }
 
 func (rl *RulesLibrary) mergeRules(target *Rules, source *Rules) error {
 	// Merge scripts
+	target.sources = append(target.sources, source.sources...)
 	if source.Scripts != "" {
 		if target.Scripts == "" {
 			target.Scripts = source.Scripts
@@ -145,11 +361,49 @@ func (rl *RulesLibrary) mergeRules(target *Rules, source *Rules) error {
 		}
 	}
 
+	// Merge called rule set entries
+	for ruleSet, entry := range source.entries {
+		if target.entries == nil {
+			target.entries = make(map[string]string)
+		}
+		target.entries[ruleSet] = entry
+	}
+
+	// Merge the on_error decisions, which keep handling the failures of the conditions of their own rule set
+	if target.errorHandlers == nil && (source.OnError != nil || len(source.errorHandlers) > 0) {
+		target.errorHandlers = make(map[string]ruleSetErrorHandler)
+	}
+	for ruleSet, handler := range source.errorHandlers {
+		target.errorHandlers[ruleSet] = handler
+	}
+	if source.OnError != nil && source.Name != "" {
+		decision := *source.OnError
+		decision.Name = source.Name + "." + rulesErrorHandler
+		handler := ruleSetErrorHandler{decision: &decision, file: source.File}
+		if source.Namespaced {
+			handler.namespace = source.Name
+		}
+		target.errorHandlers[source.Name] = handler
+	}
+
+	// Merge decision tables
+	for name, table := range source.Tables {
+		if _, exists := target.Tables[name]; exists {
+			return fmt.Errorf("duplicate table %s", name)
+		}
+		if target.Tables == nil {
+			target.Tables = make(map[string]*DecisionTable)
+		}
+		target.Tables[name] = table
+	}
+
 	// Merge conditions
 	for name, cond := range source.Conditions {
 		if _, exists := target.Conditions[name]; exists {
 			return fmt.Errorf("duplicate condition %s", name)
 		}
+		// only the default condition of the target stays the default, the others remain reachable through calls
+		cond.Default = false
 		target.Conditions[name] = cond
 	}
 
@@ -157,48 +411,119 @@ func (rl *RulesLibrary) mergeRules(target *Rules, source *Rules) error {
 }
 
 func (rl *RulesLibrary) loadFile(path string) (*Rules, error) {
-	data, err := fs.ReadFile(rl.fileSystem, path)
+	data, ok := rl.files[path]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", path)
+	}
+
+	rules, err := parseRulesFile(path, data)
 	if err != nil {
 		return nil, err
 	}
 
+	rules.File = path
+	if !strings.HasSuffix(path, ".dmn") {
+		rules.locate(path, data)
+	}
+	for name, condition := range rules.Conditions {
+		condition.File = path
+		condition.RuleSet = rules.Name
+		rules.Conditions[name] = condition
+	}
+	if rules.DefaultCondition != nil {
+		rules.DefaultCondition.File = path
+		rules.DefaultCondition.RuleSet = rules.Name
+		rules.entries = map[string]string{rules.Name: rules.DefaultCondition.Name}
+	}
+	if rules.Scripts != "" {
+		rules.sources = []scriptSource{{file: path, scripts: rules.Scripts, at: rules.scriptsAt}}
+	}
+
+	return rules, nil
+}
+
+// parseRulesFile parses a YAML rules file, or a DMN model when the file has the .dmn extension
+func parseRulesFile(path string, data []byte) (*Rules, error) {
+	if strings.HasSuffix(path, ".dmn") {
+		return LoadDMN(data)
+	}
+
 	var rules Rules
 	if err := yaml.Unmarshal(data, &rules); err != nil {
 		return nil, err
 	}
-
 	return &rules, nil
 }
 
-func (rl *RulesLibrary) scanFiles() error {
-	return fs.WalkDir(rl.fileSystem, rl.basePath, func(path string, info fs.DirEntry, err error) error {
+// readFiles reads the rule files of the library from its source
+func (rl *RulesLibrary) readFiles(ctx context.Context) (map[string][]byte, error) {
+	paths, err := rl.source.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		data, err := rl.source.Read(ctx, path)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed to read file %s: %w", path, err)
 		}
-
-		if !info.IsDir() && (strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
-			// Read file to get name and dependencies
-			data, err := fs.ReadFile(rl.fileSystem, path)
-			if err != nil {
-				return fmt.Errorf("failed to read file %s: %w", path, err)
+		if len(rl.signatureKeys) > 0 {
+			if err := rl.verifySignature(ctx, path, data); err != nil {
+				return nil, err
 			}
+		}
+		files[path] = data
+	}
+	return files, nil
+}
 
-			var rules Rules
-			if err := yaml.Unmarshal(data, &rules); err != nil {
-				return fmt.Errorf("failed to parse yaml %s: %w", path, err)
-			}
+// scanFiles maps the names of the rule sets of the files to their versions, paths and dependencies
+func (rl *RulesLibrary) scanFiles(files map[string][]byte) error {
+	rl.rulePaths = make(map[string]string)
+	rl.dependencies = make(map[string][]string)
+	rl.versions = make(map[string][]ruleSetVersion)
+	rl.files = files
 
-			if rules.Name == "" {
-				return fmt.Errorf("file %s has no name", path)
-			}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		// Parse file to get name and dependencies
+		rules, err := parseRulesFile(path, files[path])
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		if rules.Name == "" {
+			return fmt.Errorf("file %s has no name", path)
+		}
 
-			if _, exists := rl.rulePaths[rules.Name]; exists {
+		for _, other := range rl.versions[rules.Name] {
+			switch {
+			case other.version == "" && rules.Version == "":
 				return fmt.Errorf("duplicate rule set name %s", rules.Name)
+			case other.version == "" || rules.Version == "":
+				return fmt.Errorf("rule set %s has files with and without version: %s and %s", rules.Name, other.path, path)
+			case compareVersions(other.version, rules.Version) == 0:
+				return fmt.Errorf("duplicate rule set %s version %s: %s and %s", rules.Name, rules.Version, other.path, path)
 			}
-
-			rl.rulePaths[rules.Name] = path
-			rl.dependencies[rules.Name] = rules.Require
 		}
-		return nil
-	})
+
+		rl.versions[rules.Name] = append(rl.versions[rules.Name],
+			ruleSetVersion{name: rules.Name, version: rules.Version, path: path, require: rules.Require})
+	}
+
+	for name, versions := range rl.versions {
+		sort.SliceStable(versions, func(i, j int) bool {
+			return compareVersions(versions[i].version, versions[j].version) < 0
+		})
+		latest := latestVersion(versions)
+		rl.rulePaths[name] = latest.path
+		rl.dependencies[name] = latest.require
+	}
+	return nil
 }
@@ -1,12 +1,15 @@
 package yabre
 
 import (
+	"context"
 	"embed"
 	"io/fs"
 	"os"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 //go:embed test/*.yaml
@@ -77,3 +80,140 @@ func TestGetRuleNamesAndPaths(t *testing.T) {
 	assert.True(t, ok, "rule name seems missing")
 	assert.Equal(t, "loan_approval.yaml", path)
 }
+
+func TestLoadRules_NamespacedDependencies(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "./test/scoped"})
+	require.NoError(t, err)
+
+	rules, err := rl.LoadRules("checkout")
+	require.NoError(t, err)
+
+	// both dependencies have a `start` condition
+	assert.Contains(t, rules.Conditions, "start")
+	assert.Contains(t, rules.Conditions, "pricing.start")
+	assert.Contains(t, rules.Conditions, "shipping.start")
+
+	byWeight := rules.Conditions["shipping.by_weight"]
+	assert.Equal(t, "shipping", byWeight.Namespace)
+	assert.Equal(t, "shipping.by_weight_true", byWeight.True.Name)
+	assert.Equal(t, "shipping.by_weight", rules.Conditions["shipping.start"].True.Next)
+	// references to conditions outside of the rule set are kept as they are
+	assert.Equal(t, "summary", byWeight.True.Next)
+
+	// scripts of namespaced rule sets are not merged into the shared scripts
+	assert.NotContains(t, rules.Scripts, "function rate")
+	assert.NoError(t, rules.Validate())
+}
+
+func TestLoadRules_PrivateCondition(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"main.yaml": {Data: []byte(`
+name: main
+require:
+  - shipping
+conditions:
+  start:
+    default: true
+    check: "function() { return true; }"
+    true:
+      next: shipping.by_weight
+`)},
+		"shipping.yaml": {Data: []byte(`
+name: shipping
+namespaced: true
+conditions:
+  by_weight:
+    private: true
+    check: "function() { return true; }"
+    true:
+      terminate: true
+`)},
+	}
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+
+	_, err = rl.LoadRules("main")
+	require.Error(t, err)
+	assert.Equal(t, "failed to merge rule set main: condition start of rule set main can't move to private condition shipping.by_weight of rule set shipping", err.Error())
+}
+
+func TestLoadRules_InvalidNamespace(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"main.yaml": {Data: []byte(`
+name: main
+require:
+  - shipping-rules
+`)},
+		"shipping.yaml": {Data: []byte(`
+name: shipping-rules
+namespaced: true
+`)},
+	}
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+
+	_, err = rl.LoadRules("main")
+	require.Error(t, err)
+	assert.Equal(t, "failed to load dependency shipping-rules: namespaced rule set name shipping-rules should be a valid javascript identifier", err.Error())
+}
+
+type CheckoutContext struct {
+	Price    float64
+	Weight   float64
+	Shipping float64
+	Total    float64
+}
+
+func TestRunner_NamespacedRuleSets(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "./test/scoped"})
+	require.NoError(t, err)
+
+	checkout := &CheckoutContext{Price: 10.01, Weight: 2}
+	runner, err := NewRulesRunnerFromLibrary(rl, "checkout", checkout)
+	require.NoError(t, err)
+
+	result, trace, err := runner.RunRulesWithTrace(context.Background(), checkout, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"start", "pricing.start", "shipping.start", "shipping.by_weight", "summary"}, trace.Path())
+
+	// each rule set uses its own `rate` function
+	assert.Equal(t, 11.01, result.Price)
+	assert.Equal(t, 5.0, result.Shipping)
+	assert.Equal(t, 16.01, result.Total)
+}
+
+func TestRunner_NamespacedScriptsArePrivate(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"main.yaml": {Data: []byte(`
+name: main
+require:
+  - shipping
+conditions:
+  start:
+    default: true
+    check: "function() { return typeof rate === 'undefined' && typeof shipping.rate === 'undefined'; }"
+    true:
+      terminate: true
+`)},
+		"shipping.yaml": {Data: []byte(`
+name: shipping
+namespaced: true
+scripts: |
+  function rate() { return 2.5; }
+`)},
+	}
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(rl, "main", &rulesContext)
+	require.NoError(t, err)
+
+	_, trace, err := runner.RunRulesWithTrace(context.Background(), &rulesContext, nil)
+	require.NoError(t, err)
+	require.Equal(t, TraceResult, trace.Events[1].Type)
+	assert.Equal(t, true, trace.Events[1].Result)
+}
@@ -4,7 +4,6 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/dop251/goja"
 	"gopkg.in/yaml.v2"
 )
 
@@ -114,8 +113,6 @@ conditions:
 }
 
 func TestLoadRulesFromYaml_InvalidYAMLError(t *testing.T) {
-	runner := &RulesRunner[any]{}
-	
 	invalidYAML := []byte(`
 name: "invalid
 conditions:
@@ -123,7 +120,7 @@ conditions:
     description: "Missing quote
 `)
 	
-	rules, err := runner.loadRulesFromYaml(invalidYAML)
+	rules, err := loadRulesFromYaml(invalidYAML)
 	
 	if err == nil {
 		t.Fatal("Expected error for invalid YAML")
@@ -137,11 +134,9 @@ conditions:
 }
 
 func TestLoadRulesFromYaml_EmptyYAML(t *testing.T) {
-	runner := &RulesRunner[any]{}
-	
 	emptyYAML := []byte("")
 	
-	rules, err := runner.loadRulesFromYaml(emptyYAML)
+	rules, err := loadRulesFromYaml(emptyYAML)
 	
 	if err != nil {
 		t.Fatalf("Unexpected error for empty YAML: %v", err)
@@ -154,190 +149,51 @@ func TestLoadRulesFromYaml_EmptyYAML(t *testing.T) {
 	}
 }
 
-func TestAddJsFunctions_ScriptInjectionErrors(t *testing.T) {
-	vm := goja.New()
-	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
-		Rules: &Rules{
-			Scripts: "invalid javascript {{{",
-		},
-	}
-	
-	err := runner.addJsFunctions(vm)
-	
-	if err == nil {
-		t.Fatal("Expected error for invalid JavaScript")
-	}
-	if !strings.Contains(err.Error(), "error injecting scripts into vm") {
-		t.Errorf("Expected script injection error, got: %v", err)
-	}
-}
-
-func TestAddJsFunctions_InvalidJavaScriptSyntax(t *testing.T) {
-	vm := goja.New()
-	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
-		Rules: &Rules{
-			Conditions: map[string]Condition{
-				"test": {
-					Name:  "test",
-					Check: "function check() { return",  // Invalid JS
-				},
-			},
-		},
-	}
-	
-	err := runner.addJsFunctions(vm)
-	
-	if err == nil {
-		t.Fatal("Expected error for invalid JavaScript function")
-	}
-	if !strings.Contains(err.Error(), "error injecting condition function into vm") {
-		t.Errorf("Expected condition function injection error, got: %v", err)
-	}
-}
-
-func TestAddJsFunctions_MissingCheckFunctions(t *testing.T) {
-	vm := goja.New()
-	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
-		Rules: &Rules{
-			Conditions: map[string]Condition{
-				"test": {
-					Name: "test",
-					// No check function
-					True: &Decision{
-						Name:   "test_true",
-						Action: "function() { return true; }",
-					},
-				},
-			},
-		},
-	}
-	
-	err := runner.addJsFunctions(vm)
-	
-	// Should not error on missing check, only when trying to inject non-empty functions
-	if err != nil {
+func TestRules_UnmarshalYAML_SwitchCases(t *testing.T) {
+	yamlData := `
+name: "switch"
+conditions:
+  route:
+    check: "function() { return context.plan; }"
+    cases:
+      gold:
+        next: other
+      default:
+        terminate: true
+`
+	var rules Rules
+	if err := yaml.Unmarshal([]byte(yamlData), &rules); err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
-	
-	// Verify no function was registered for empty check
-	if _, exists := runner.functionNames["test"]; exists {
-		t.Error("Expected no function name mapping for empty check")
-	}
-}
-
-func TestInjectJSFunction_VariousFunctionNamePatterns(t *testing.T) {
-	tests := []struct {
-		name         string
-		funcCode     string
-		defaultName  string
-		expectedName string
-	}{
-		{
-			name:         "Named function",
-			funcCode:     "function myFunc() { return true; }",
-			defaultName:  "default",
-			expectedName: "myFunc",
-		},
-		{
-			name:         "Named function with spaces",
-			funcCode:     "function    spacedFunc   () { return true; }",
-			defaultName:  "default",
-			expectedName: "spacedFunc",
-		},
-		{
-			name:         "Function with parameters",
-			funcCode:     "function withParams(a, b) { return a + b; }",
-			defaultName:  "default",
-			expectedName: "withParams",
-		},
-		{
-			name:         "No function keyword",
-			funcCode:     "() => { return true; }",
-			defaultName:  "arrowDefault",
-			expectedName: "arrowDefault",
-		},
-	}
-	
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			vm := goja.New()
-			runner := &RulesRunner[any]{
-				functionNames: make(map[string]string),
-			}
-			
-			err := runner.injectJSFunction(vm, tt.defaultName, tt.funcCode)
-			
-			if err != nil {
-				t.Fatalf("Unexpected error: %v", err)
-			}
-			
-			if runner.functionNames[tt.defaultName] != tt.expectedName {
-				t.Errorf("Expected function name '%s', got: '%s'", tt.expectedName, runner.functionNames[tt.defaultName])
-			}
-			
-			// Verify function exists in VM
-			funcVal := vm.Get(tt.expectedName)
-			if funcVal == nil || funcVal == goja.Undefined() {
-				t.Errorf("Expected function '%s' to exist in VM", tt.expectedName)
-			}
-		})
-	}
-}
 
-func TestInjectJSFunction_ArrowFunctions(t *testing.T) {
-	vm := goja.New()
-	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
+	route := rules.Conditions["route"]
+	if route.Cases["gold"].Name != "route_gold" || route.Cases["gold"].Case != "gold" {
+		t.Errorf("Expected case decision to be named after condition and case, got: %s", route.Cases["gold"].Name)
 	}
-	
-	arrowFunc := "() => true"
-	err := runner.injectJSFunction(vm, "arrowTest", arrowFunc)
-	
-	if err != nil {
-		t.Fatalf("Unexpected error: %v", err)
-	}
-	
-	// Should use default name for arrow functions
-	if runner.functionNames["arrowTest"] != "arrowTest" {
-		t.Errorf("Expected default name 'arrowTest' for arrow function, got: %s", runner.functionNames["arrowTest"])
+	if route.Cases[DefaultCase].Name != "route_default" {
+		t.Errorf("Expected default case to be named route_default, got: %s", route.Cases[DefaultCase].Name)
 	}
 }
 
-func TestInjectJSFunction_AnonymousFunctions(t *testing.T) {
-	vm := goja.New()
-	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
-	}
-	
-	anonFunc := "function() { return 42; }"
-	err := runner.injectJSFunction(vm, "anonTest", anonFunc)
-	
-	if err != nil {
-		t.Fatalf("Unexpected error: %v", err)
-	}
-	
-	// Should use default name for anonymous functions
-	if runner.functionNames["anonTest"] != "anonTest" {
-		t.Errorf("Expected default name 'anonTest' for anonymous function, got: %s", runner.functionNames["anonTest"])
-	}
-}
+func TestRules_UnmarshalYAML_SwitchWithBranches(t *testing.T) {
+	yamlData := `
+name: "switch"
+conditions:
+  route:
+    check: "function() { return context.plan; }"
+    true:
+      terminate: true
+    cases:
+      gold:
+        terminate: true
+`
+	var rules Rules
+	err := yaml.Unmarshal([]byte(yamlData), &rules)
 
-func TestInjectJSFunction_InvalidFunctionCode(t *testing.T) {
-	vm := goja.New()
-	runner := &RulesRunner[any]{
-		functionNames: make(map[string]string),
-	}
-	
-	invalidCode := "function broken() { return"
-	err := runner.injectJSFunction(vm, "invalid", invalidCode)
-	
 	if err == nil {
-		t.Fatal("Expected error for invalid function code")
+		t.Fatal("Expected error for switch condition with true/false branches")
 	}
-	if !strings.Contains(err.Error(), "error injecting function") {
-		t.Errorf("Expected function injection error, got: %v", err)
+	if !strings.Contains(err.Error(), "condition route can't have both cases and true/false branches") {
+		t.Errorf("Expected cases and branches error, got: %v", err)
 	}
-}
\ No newline at end of file
+}
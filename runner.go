@@ -1,20 +1,48 @@
 package yabre
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/dop251/goja"
 )
 
+// RulesRunner runs business rules against a typed context.
+// The runner's state is computed at construction and only read by runs, so a single runner
+// may be shared across goroutines; callbacks may then be called concurrently.
 type RulesRunner[Context interface{}] struct {
+	// Rules must not be modified after construction
 	Rules         *Rules
 	Context       *Context
 	debugCallback func(...interface{})
-	goFunctions   map[string]func(...interface{}) (interface{}, error)
+	goFunctions   map[string]goFunction
 	// callback to be called when a decision is made
 	decisionCallback func(msg string, args ...interface{})
-	// mapping of js functions in business rules to standard names
-	functionNames map[string]string
+	// maximum number of conditions evaluated in a single run; 0 means unlimited
+	maxSteps int
+	// maximum number of times a single condition may be evaluated in a run; 0 means unlimited
+	maxVisits int
+	// scripts and functions compiled at construction
+	compiled *compiledRules
+	// pool of javascript runtimes reused across runs
+	runtimes sync.Pool
+	// exposes context struct fields under the name of their json tag
+	jsonFieldNames bool
+	// snapshots the context around decisions and reports the changes to auditCallback, if not nil
+	audit         bool
+	auditCallback func(changes []ContextChange)
+}
+
+// DefaultMaxSteps is the number of conditions a single run may evaluate unless changed with WithMaxSteps
+const DefaultMaxSteps = 10000
+
+// goFunction is a Go function exposed to the rules
+type goFunction struct {
+	fn func(...interface{}) (interface{}, error)
+	// withContext is true when the function expects the run's context.Context as its first argument
+	withContext bool
 }
 
 type WithOption[Context interface{}] func(*RulesRunner[Context]) error
@@ -27,10 +55,14 @@ func WithDebugCallback[Context interface{}](callback func(...interface{})) WithO
 	}
 }
 
+// WithGoFunction exposes a Go function to the rules under the given name.
+// If the first argument of the function is a context.Context, it receives the context of the run,
+// so the function can honour the cancellation and deadline passed to RunRulesContext: the run returns as soon as
+// the context is done, and the function must stop using its arguments then. Runs wait for other functions to return.
 func WithGoFunction[Context interface{}](name string, f any) WithOption[Context] {
 	return func(runner *RulesRunner[Context]) error {
 		if runner.goFunctions == nil {
-			runner.goFunctions = make(map[string]func(...interface{}) (interface{}, error))
+			runner.goFunctions = make(map[string]goFunction)
 		}
 
 		var fn func(...interface{}) (interface{}, error)
@@ -45,7 +77,17 @@ func WithGoFunction[Context interface{}](name string, f any) WithOption[Context]
 			fn = f.(func(...interface{}) (interface{}, error))
 		}
 
-		runner.goFunctions[name] = fn
+		runner.goFunctions[name] = goFunction{fn: fn, withContext: acceptsContext(f)}
+		return nil
+	}
+}
+
+// WithJSONFieldNames exposes the fields of structs in the context, and of structs returned by go functions,
+// under the name of their json tag instead of their Go name, e.g. `context.weight` for a `Weight` field tagged `json:"weight"`.
+// Fields without a json tag keep their Go name and fields tagged `json:"-"` are hidden from the rules.
+func WithJSONFieldNames[Context interface{}]() WithOption[Context] {
+	return func(runner *RulesRunner[Context]) error {
+		runner.jsonFieldNames = true
 		return nil
 	}
 }
@@ -57,11 +99,28 @@ func WithDecisionCallback[Context interface{}](callback func(msg string, args ..
 	}
 }
 
-func (runner *RulesRunner[Context]) getFunctionName(name string) string {
-	if functionName, ok := runner.functionNames[name]; ok {
-		return functionName
+// WithMaxSteps limits the number of conditions evaluated in a single run; 0 disables the limit.
+// When the limit is exceeded, RunRules returns a *CycleError.
+func WithMaxSteps[Context interface{}](maxSteps int) WithOption[Context] {
+	return func(runner *RulesRunner[Context]) error {
+		if maxSteps < 0 {
+			return fmt.Errorf("invalid max steps: %d", maxSteps)
+		}
+		runner.maxSteps = maxSteps
+		return nil
+	}
+}
+
+// WithMaxVisits limits the number of times a single condition may be evaluated in a run; 0 disables the limit.
+// When the limit is exceeded, RunRules returns a *CycleError.
+func WithMaxVisits[Context interface{}](maxVisits int) WithOption[Context] {
+	return func(runner *RulesRunner[Context]) error {
+		if maxVisits < 0 {
+			return fmt.Errorf("invalid max visits: %d", maxVisits)
+		}
+		runner.maxVisits = maxVisits
+		return nil
 	}
-	return name
 }
 
 func NewRulesRunnerFromLibrary[Context interface{}](
@@ -76,11 +135,37 @@ func NewRulesRunnerFromLibrary[Context interface{}](
 		return nil, fmt.Errorf("failed to load rules: %w", err)
 	}
 
+	return newRulesRunner(rules, context, options)
+}
+
+// Deprecated: Use NewRulesRunnerFromLibrary instead
+func NewRulesRunnerFromYaml[Context interface{}](yamlData []byte, context *Context, options ...WithOption[Context]) (*RulesRunner[Context], error) {
+	// Load the rules from the YAML data
+	rules, err := loadRulesFromYaml(yamlData)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRulesRunner(rules, context, options)
+}
+
+// NewRulesRunnerFromDMN creates a runner for the decision tables of a DMN model, see LoadDMN
+func NewRulesRunnerFromDMN[Context interface{}](dmnData []byte, context *Context, options ...WithOption[Context]) (*RulesRunner[Context], error) {
+	rules, err := LoadDMN(dmnData)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRulesRunner(rules, context, options)
+}
+
+// newRulesRunner creates a runner for the loaded rules, applies the options and compiles the rules
+func newRulesRunner[Context interface{}](rules *Rules, context *Context, options []WithOption[Context]) (*RulesRunner[Context], error) {
 	runner := &RulesRunner[Context]{
 		Context:          context,
 		Rules:            rules,
-		functionNames:    map[string]string{},
 		decisionCallback: func(msg string, args ...interface{}) {},
+		maxSteps:         DefaultMaxSteps,
 	}
 
 	// Execute options
@@ -90,73 +175,267 @@ func NewRulesRunnerFromLibrary[Context interface{}](
 		}
 	}
 
+	if err := runner.compile(); err != nil {
+		return nil, fmt.Errorf("failed to compile rules: %w", err)
+	}
+
 	return runner, nil
 }
 
-// Deprecated: Use NewRulesRunnerFromLibrary instead
-func NewRulesRunnerFromYaml[Context interface{}](yamlData []byte, context *Context, options ...WithOption[Context]) (*RulesRunner[Context], error) {
-	runner := &RulesRunner[Context]{
-		Context:          context,
-		functionNames:    map[string]string{},
-		decisionCallback: func(msg string, args ...interface{}) {},
+// RulesHash returns the content hash of the rules of the runner, dependencies included, see Rules.Hash
+func (rr *RulesRunner[Context]) RulesHash() string {
+	return rr.Rules.Hash
+}
+
+// RunRules runs the rules from startCondition, or from the default condition if nil.
+// Rule sets in agenda mode run all their conditions and ignore startCondition.
+func (rr *RulesRunner[Context]) RunRules(rulesContext *Context, startCondition *Condition) (*Context, error) {
+	return rr.RunRulesContext(context.Background(), rulesContext, startCondition)
+}
+
+// RunRulesContext runs the rules like RunRules but stops the execution when ctx is canceled or its deadline is exceeded.
+// Running javascript is interrupted, and the returned error wraps ctx.Err(),
+// so it can be checked with errors.Is(err, context.DeadlineExceeded) or errors.Is(err, context.Canceled).
+func (rr *RulesRunner[Context]) RunRulesContext(ctx context.Context, rulesContext *Context, startCondition *Condition) (*Context, error) {
+	return rr.run(ctx, rulesContext, startCondition, nil)
+}
+
+// run runs the rules and records the execution into trace, if not nil
+func (rr *RulesRunner[Context]) run(ctx context.Context, rulesContext *Context, startCondition *Condition, trace *Trace) (*Context, error) {
+	rules := rr.Rules
+
+	// agenda rule sets evaluate all their conditions, there's no start condition
+	if rules.Mode == ModeAgenda {
+		return rr.execute(ctx, rulesContext, trace, func(exec *execution[Context]) error {
+			return exec.runAgenda()
+		})
 	}
 
-	// Execute options
-	for _, op := range options {
-		err := op(runner)
-		if err != nil {
-			return nil, err
-		}
+	if startCondition == nil {
+		startCondition = rules.DefaultCondition
 	}
 
-	// Load the rules from the YAML data
-	rules, err := runner.loadRulesFromYaml(yamlData)
-	if err != nil {
+	if startCondition == nil && rules.DefaultCondition == nil {
+		err := fmt.Errorf("no default condition found")
+		trace.record(TraceEvent{Type: TraceError, Error: err.Error()})
 		return nil, err
 	}
-	runner.Rules = rules
 
-	return runner, nil
+	// Start running the conditions from the first condition
+	return rr.execute(ctx, rulesContext, trace, func(exec *execution[Context]) error {
+		return exec.run(startCondition)
+	})
 }
 
-func (rr *RulesRunner[Context]) RunRules(context *Context, startCondition *Condition) (*Context, error) {
+// execute prepares a javascript runtime with the context, go functions, scripts and functions of the rules,
+// runs the rules with it and returns the updated context
+func (rr *RulesRunner[Context]) execute(ctx context.Context, rulesContext *Context, trace *Trace, runRules func(*execution[Context]) error) (*Context, error) {
 	rules := rr.Rules
-	vm := goja.New()
 
-	// Add context to vm
-	vm.Set("context", *context)
+	// Compile the scripts and check and action functions, unless done at construction
+	compiled := rr.compiled
+	if compiled == nil {
+		var err error
+		if compiled, err = compileRules(rules, rr.reservedNames()); err != nil {
+			trace.record(TraceEvent{Type: TraceError, Error: err.Error()})
+			return nil, err
+		}
+	}
 
-	// Add debug function to vm
-	if rr.debugCallback != nil {
-		vm.Set("debug", rr.debugCallback)
+	runtime := rr.getRuntime()
+	vm := runtime.vm
+
+	defer rr.putRuntime(runtime, compiled)
+
+	// Interrupt the vm as soon as the context is done
+	if ctx.Done() != nil {
+		stop := make(chan struct{})
+		watching := make(chan struct{})
+		go func() {
+			defer close(watching)
+			select {
+			case <-ctx.Done():
+				vm.Interrupt(ctx.Err())
+			case <-stop:
+			}
+		}()
+		// the watcher must be gone before the vm returns to the pool
+		defer func() {
+			close(stop)
+			<-watching
+		}()
 	}
 
+	// Add context to vm
+	rr.setContext(vm, rulesContext)
+
 	// Add go functions to vm
 	if rr.goFunctions != nil {
 		for name, f := range rr.goFunctions {
-			vm.Set(name, f)
+			vm.Set(name, bindGoFunction(ctx, name, f))
 		}
 	}
 
-	// Add all js functions to the vm
-	err := rr.addJsFunctions(vm)
+	// Run the scripts and evaluate the check and action functions
+	functions, err := compiled.load(vm)
 	if err != nil {
+		err = interrupted(ctx, err)
+		trace.record(TraceEvent{Type: TraceError, Error: err.Error()})
 		return nil, err
 	}
 
-	if startCondition == nil {
-		startCondition = rules.DefaultCondition
+	exec := rr.newExecution(vm, functions, rules)
+	exec.ctx = ctx
+	exec.trace = trace
+	err = interrupted(ctx, runRules(exec))
+
+	// Get the updated context
+	if contextErr := rr.exportContext(vm, rulesContext); contextErr != nil {
+		trace.record(TraceEvent{Type: TraceError, Error: contextErr.Error()})
+		if err == nil {
+			err = contextErr
+		}
 	}
 
-	if startCondition == nil && rules.DefaultCondition == nil {
-		return nil, fmt.Errorf("no default condition found")
+	return rulesContext, err
+}
+
+// compile compiles the scripts and functions of the rules, so runs don't have to parse javascript again.
+// It must only be called during construction: runs only read the compiled rules, so a runner can be shared across goroutines.
+func (rr *RulesRunner[Context]) compile() error {
+	compiled, err := compileRules(rr.Rules, rr.reservedNames())
+	if err != nil {
+		return err
 	}
+	rr.compiled = compiled
+	return nil
+}
 
-	// Start running the conditions from the first condition
-	err = rr.runCondition(vm, rules, startCondition)
+// reservedNames lists the global names the engine provides to the rules
+func (rr *RulesRunner[Context]) reservedNames() []string {
+	names := []string{"context"}
+	if rr.debugCallback != nil {
+		names = append(names, "debug")
+	}
+	for name := range rr.goFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
-	// Get the updated context
-	*context = vm.Get("context").ToObject(vm).Export().(Context)
+// pooledRuntime is a javascript runtime along with the globals it had when created, which are restored after every run
+type pooledRuntime struct {
+	vm      *goja.Runtime
+	globals map[string]goja.Value
+}
+
+// getRuntime returns a javascript runtime from the pool or creates a new one
+func (rr *RulesRunner[Context]) getRuntime() *pooledRuntime {
+	if runtime, ok := rr.runtimes.Get().(*pooledRuntime); ok {
+		return runtime
+	}
 
-	return context, err
+	vm := goja.New()
+	if rr.jsonFieldNames {
+		vm.SetFieldNameMapper(jsonFieldNameMapper{})
+	}
+
+	// Add debug function to vm
+	if rr.debugCallback != nil {
+		vm.Set("debug", rr.debugCallback)
+	}
+
+	runtime := &pooledRuntime{vm: vm, globals: map[string]goja.Value{}}
+	global := vm.GlobalObject()
+	for _, name := range global.GetOwnPropertyNames() {
+		runtime.globals[name] = global.Get(name)
+	}
+	return runtime
+}
+
+// putRuntime returns a javascript runtime to the pool once a run is over, without the globals created by the run,
+// so nothing a run leaves behind is seen by the next runs. Runtimes whose globals can't be restored are dropped.
+func (rr *RulesRunner[Context]) putRuntime(runtime *pooledRuntime, compiled *compiledRules) {
+	// let, const and class declarations of the scripts can't be removed nor declared again
+	if compiled.lexicalScripts || !runtime.restoreGlobals() {
+		return
+	}
+	runtime.vm.ClearInterrupt()
+	rr.runtimes.Put(runtime)
+}
+
+// restoreGlobals deletes the globals created since the runtime was created, including the context, the go functions,
+// the scripts and the implicit globals of the rules. It returns false if a global of the new runtime has been replaced.
+func (runtime *pooledRuntime) restoreGlobals() bool {
+	global := runtime.vm.GlobalObject()
+	for _, name := range global.GetOwnPropertyNames() {
+		if initial, ok := runtime.globals[name]; ok {
+			if !global.Get(name).SameAs(initial) {
+				return false
+			}
+			continue
+		}
+		if err := global.Delete(name); err != nil {
+			// var and function declarations of the scripts can't be deleted, the scripts declare them again on the next run
+			if err := global.Set(name, goja.Undefined()); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// interrupted replaces err with the cause of the interruption if ctx is done
+func interrupted(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("rules execution interrupted: %w", ctx.Err())
+	}
+	return err
+}
+
+// bindGoFunction binds a go function to the context of a run.
+// The function isn't called once ctx is done. Functions taking a context.Context are abandoned as soon as ctx is done,
+// since they can stop themselves, other functions are waited for so they never touch their arguments,
+// like the rules context, after the run is over. Errors are returned as *GoFunctionError.
+func bindGoFunction(ctx context.Context, name string, f goFunction) func(...interface{}) (interface{}, error) {
+	call := func(args ...interface{}) (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// functions without context can't be stopped and context can never be done, call the function directly
+		if !f.withContext || ctx.Done() == nil {
+			if f.withContext {
+				args = append([]interface{}{ctx}, args...)
+			}
+			return f.fn(args...)
+		}
+		args = append([]interface{}{ctx}, args...)
+
+		type result struct {
+			value interface{}
+			err   error
+		}
+		done := make(chan result, 1)
+		go func() {
+			value, err := f.fn(args...)
+			done <- result{value, err}
+		}()
+
+		select {
+		case r := <-done:
+			return r.value, r.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return func(args ...interface{}) (interface{}, error) {
+		value, err := call(args...)
+		if err != nil {
+			return nil, &GoFunctionError{Function: name, Err: err}
+		}
+		return value, nil
+	}
 }
@@ -0,0 +1,160 @@
+package yabre
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type LoyaltyContext struct {
+	Points    float64
+	Items     int
+	Blocked   bool
+	BulkBonus bool
+	Tier      string
+	Discount  float64
+}
+
+func TestRunner_Agenda(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/loyalty_agenda.yaml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		context  LoyaltyContext
+		expected LoyaltyContext
+		fired    []string
+	}{
+		{
+			name:     "Bonus points make the customer gold",
+			context:  LoyaltyContext{Points: 500, Items: 12},
+			expected: LoyaltyContext{Points: 1100, Items: 12, BulkBonus: true, Tier: "gold", Discount: 0.1},
+			fired:    []string{"silver_tier", "bulk_bonus", "gold_tier", "gold_discount"},
+		},
+		{
+			name:     "Silver customer",
+			context:  LoyaltyContext{Points: 700, Items: 1},
+			expected: LoyaltyContext{Points: 700, Items: 1, Tier: "silver"},
+			fired:    []string{"silver_tier"},
+		},
+		{
+			name:     "Blocked customer",
+			context:  LoyaltyContext{Points: 2000, Blocked: true, Discount: 0.5},
+			expected: LoyaltyContext{Points: 2000, Blocked: true},
+			fired:    []string{"blocked"},
+		},
+		{
+			name:     "Nothing fires",
+			context:  LoyaltyContext{Points: 10},
+			expected: LoyaltyContext{Points: 10},
+			fired:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired := []string{}
+			runner, err := NewRulesRunnerFromYaml(yamlFile, &tt.context,
+				WithDecisionCallback[LoyaltyContext](func(msg string, args ...interface{}) {
+					if msg == "Firing condition: [%s]" {
+						fired = append(fired, args[0].(string))
+					}
+				}))
+			require.NoError(t, err)
+
+			result, err := runner.RunRules(&tt.context, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, *result)
+			assert.Equal(t, tt.fired, fired)
+		})
+	}
+}
+
+func TestRunner_AgendaTrace(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/loyalty_agenda.yaml")
+	require.NoError(t, err)
+
+	loyalty := LoyaltyContext{Points: 700}
+	runner, err := NewRulesRunnerFromYaml(yamlFile, &loyalty)
+	require.NoError(t, err)
+
+	_, trace, err := runner.RunRulesWithTrace(context.Background(), &loyalty, nil)
+	require.NoError(t, err)
+
+	// every round evaluates the conditions by salience until one fires
+	assert.Equal(t, []string{
+		"blocked", "gold_tier", "silver_tier",
+		"blocked", "gold_tier", "silver_tier", "bulk_bonus", "gold_discount",
+	}, trace.Path())
+	assert.Equal(t, TraceTerminate, trace.Events[len(trace.Events)-1].Type)
+}
+
+func TestRunner_AgendaLimit(t *testing.T) {
+	yamlRules := `
+name: endless
+mode: agenda
+conditions:
+  increment:
+    expr: context.count >= 0
+    true:
+      action: "function() { context.count++; }"
+`
+	rulesContext := map[string]interface{}{"count": 0}
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext, WithMaxSteps[map[string]interface{}](50))
+	require.NoError(t, err)
+
+	result, err := runner.RunRules(&rulesContext, nil)
+	var cycleErr *CycleError
+	require.True(t, errors.As(err, &cycleErr), err)
+	assert.Equal(t, 50, cycleErr.Limit)
+	assert.EqualValues(t, 50, (*result)["count"])
+}
+
+func TestRules_AgendaMode(t *testing.T) {
+	var rules Rules
+	err := yaml.Unmarshal([]byte(`
+name: invalid
+mode: backwards
+conditions: {}
+`), &rules)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown mode backwards")
+
+	err = yaml.Unmarshal([]byte(`
+name: agenda
+mode: agenda
+conditions:
+  no_true:
+    expr: "true"
+    false:
+      terminate: true
+  moves:
+    expr: "true"
+    true:
+      next: no_true
+  switch:
+    check: "function() { return 'a'; }"
+    cases:
+      a:
+        terminate: true
+`), &rules)
+	require.NoError(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(rules.Validate(), &validationErr))
+	messages := []string{}
+	for _, issue := range validationErr.Issues {
+		messages = append(messages, issue.Condition+": "+issue.Message)
+	}
+	assert.Equal(t, []string{
+		"moves: next condition of true branch is not supported in agenda mode",
+		"no_true: false branch is not supported in agenda mode",
+		"no_true: true branch is not defined",
+		"switch: switch conditions are not supported in agenda mode",
+	}, messages)
+}
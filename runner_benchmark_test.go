@@ -0,0 +1,88 @@
+package yabre
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func newAliquotingContext() RecipeContext {
+	return RecipeContext{
+		Container: Container{Amount: 2100},
+		OrderItems: []OrderItem{
+			{Ref: 1, Rank: 1, ProductType: "powder", Amount: 300, Concentration: 10, Solvent: "water", OrderType: "primary", State: "pending"},
+			{Ref: 2, Rank: 2, ProductType: "solution", Amount: 500, Concentration: 10, Solvent: "water", OrderType: "primary", State: "pending"},
+			{Ref: 3, Rank: 3, ProductType: "solution", Amount: 300, Concentration: 10, Solvent: "water", OrderType: "early", State: "pending"},
+		},
+		Products: []Product{},
+	}
+}
+
+func newAliquotingRunner(b *testing.B) *RulesRunner[RecipeContext] {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "test", FileSystem: testFs})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	context := newAliquotingContext()
+	runner, err := NewRulesRunnerFromLibrary(rl, "aliquoting-rules", &context,
+		WithDebugCallback[RecipeContext](func(...interface{}) {}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return runner
+}
+
+// BenchmarkRunRules_Compiled runs the rules with precompiled programs and pooled runtimes
+func BenchmarkRunRules_Compiled(b *testing.B) {
+	runner := newAliquotingRunner(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		context := newAliquotingContext()
+		if _, err := runner.RunRules(&context, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRunRules_Interpreted runs the rules the way they were run before compilation:
+// a new runtime per run, with scripts and functions parsed on every run
+func BenchmarkRunRules_Interpreted(b *testing.B) {
+	runner := newAliquotingRunner(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		context := newAliquotingContext()
+		vm := goja.New()
+		vm.Set("context", context)
+		vm.Set("debug", runner.debugCallback)
+		compiled, err := compileRules(runner.Rules, runner.reservedNames())
+		if err != nil {
+			b.Fatal(err)
+		}
+		functions, err := compiled.load(vm)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := runner.newExecution(vm, functions, runner.Rules).run(runner.Rules.DefaultCondition); err != nil {
+			b.Fatal(err)
+		}
+		_ = vm.Get("context").ToObject(vm).Export().(RecipeContext)
+	}
+}
+
+// BenchmarkRunRules_CompiledParallel runs the rules concurrently on a shared runner
+func BenchmarkRunRules_CompiledParallel(b *testing.B) {
+	runner := newAliquotingRunner(b)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			context := newAliquotingContext()
+			if _, err := runner.RunRules(&context, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
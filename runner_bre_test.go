@@ -1,6 +1,7 @@
 package yabre
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -48,3 +49,90 @@ func TestRunnerBre(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "RuleSet3 executed", debugMessage)
 }
+
+func TestRunnerBreSwitch(t *testing.T) {
+	breContext := &BreContext{}
+	var debugMessage string
+
+	ruleLibrary, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "./test/bre"})
+	assert.NoError(t, err)
+
+	runner, err := NewRulesRunnerFromLibrary(ruleLibrary, "main-switch", breContext,
+		WithDebugCallback[BreContext](
+			func(data ...any) {
+				if len(data) > 0 {
+					debugMessage = fmt.Sprintf("%v", data[0])
+				}
+			}))
+	assert.NoError(t, err)
+
+	for ruleSet, expected := range map[string]string{
+		"ruleset1": "RuleSet1 executed",
+		"ruleset2": "RuleSet2 executed",
+		"":         "RuleSet3 executed",
+		"unknown":  "RuleSet3 executed",
+	} {
+		breContext.RuleSet = ruleSet
+		_, trace, err := runner.RunRulesWithTrace(context.Background(), breContext, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, debugMessage, ruleSet)
+		assert.Equal(t, ruleSet, trace.Events[1].Result)
+	}
+}
+
+func TestRunner_SwitchNumericCases(t *testing.T) {
+	yamlRules := `
+name: "numeric-switch"
+conditions:
+  tier:
+    default: true
+    check: "function() { return context.points >= 100 ? 2 : context.points > 0 ? 1 : 0; }"
+    cases:
+      1:
+        action: "function() { context.tier = 'silver'; }"
+        terminate: true
+      2:
+        action: "function() { context.tier = 'gold'; }"
+        terminate: true
+`
+	for points, expected := range map[int]interface{}{150: "gold", 50: "silver", 0: nil} {
+		rulesContext := map[string]interface{}{"points": points}
+		runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext)
+		assert.NoError(t, err)
+
+		result, err := runner.RunRules(&rulesContext, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, (*result)["tier"])
+	}
+}
+
+func TestRunner_SwitchUnmatchedValue(t *testing.T) {
+	yamlRules := `
+name: "unmatched-switch"
+conditions:
+  tier:
+    default: true
+    check: "function() { return context.plan; }"
+    cases:
+      gold:
+        action: "function() { context.tier = 'gold'; }"
+        next: finish
+  finish:
+    expr: "true"
+    true:
+      set:
+        done: true
+`
+	rulesContext := map[string]interface{}{"plan": "bronze"}
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext)
+	assert.NoError(t, err)
+
+	result, trace, err := runner.RunRulesWithTrace(context.Background(), &rulesContext, nil)
+	assert.NoError(t, err)
+
+	// without a default case, a value matching no case ends the run after the switch
+	assert.Equal(t, []string{"tier"}, trace.Path())
+	assert.Equal(t, "bronze", trace.Events[1].Result)
+	assert.Nil(t, (*result)["tier"])
+	assert.Nil(t, (*result)["done"])
+}
@@ -0,0 +1,119 @@
+package yabre
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type OrderContext struct {
+	Total    float64
+	Discount float64
+	Status   string
+	Audited  bool
+}
+
+func newOrdersRunner(t *testing.T, order *OrderContext) *RulesRunner[OrderContext] {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "./test/calls"})
+	require.NoError(t, err)
+
+	runner, err := NewRulesRunnerFromLibrary(rl, "orders", order)
+	require.NoError(t, err)
+	return runner
+}
+
+func TestRunner_CallRuleSet(t *testing.T) {
+	order := &OrderContext{Total: 150}
+	runner := newOrdersRunner(t, order)
+
+	result, trace, err := runner.RunRulesWithTrace(context.Background(), order, nil)
+	require.NoError(t, err)
+
+	// the called rule sets return to the caller, which moves on to its next condition
+	assert.Equal(t, []string{"start", "eligible", "record", "finish"}, trace.Path())
+	assert.Equal(t, 10.0, result.Discount)
+	assert.True(t, result.Audited)
+	assert.Equal(t, "discounted", result.Status)
+
+	calls := []TraceEvent{}
+	for _, event := range trace.Events {
+		if event.Type == TraceCall || event.Type == TraceReturn {
+			calls = append(calls, event)
+		}
+	}
+	require.Len(t, calls, 4)
+
+	assert.Equal(t, TraceCall, calls[0].Type)
+	assert.Equal(t, "discount", calls[0].Call)
+	assert.Equal(t, "eligible", calls[0].Next)
+	assert.Equal(t, []CallFrame{{RuleSet: "discount", Condition: "start"}}, calls[0].CallStack)
+
+	assert.Equal(t, TraceCall, calls[1].Type)
+	assert.Equal(t, "audit", calls[1].Call)
+	assert.Equal(t, []CallFrame{{RuleSet: "discount", Condition: "start"}, {RuleSet: "audit", Condition: "eligible"}}, calls[1].CallStack)
+
+	assert.Equal(t, TraceReturn, calls[2].Type)
+	assert.Equal(t, "audit", calls[2].Call)
+	assert.Equal(t, "eligible", calls[2].Condition)
+
+	assert.Equal(t, TraceReturn, calls[3].Type)
+	assert.Equal(t, "discount", calls[3].Call)
+	assert.Equal(t, "start", calls[3].Condition)
+	assert.Empty(t, calls[3].CallStack)
+}
+
+func TestRunner_CallRuleSetWithoutNestedCall(t *testing.T) {
+	order := &OrderContext{Total: 50}
+	runner := newOrdersRunner(t, order)
+
+	result, trace, err := runner.RunRulesWithTrace(context.Background(), order, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"start", "eligible", "finish"}, trace.Path())
+	assert.False(t, result.Audited)
+	assert.Equal(t, "full price", result.Status)
+}
+
+func TestRunner_CallRuleSetError(t *testing.T) {
+	order := &OrderContext{Total: 2000}
+	runner := newOrdersRunner(t, order)
+
+	_, trace, err := runner.RunRulesWithTrace(context.Background(), order, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error while evaluating condition 'record' (call stack: discount called from condition 'start', audit called from condition 'eligible'): ")
+	assert.Contains(t, err.Error(), "discount needs approval")
+
+	last := trace.Events[len(trace.Events)-1]
+	assert.Equal(t, TraceError, last.Type)
+	assert.Len(t, last.CallStack, 2)
+}
+
+func TestRunner_CallUnknownRuleSet(t *testing.T) {
+	yamlRules := `
+name: "unknown-call"
+conditions:
+  start:
+    default: true
+    check: "function() { return true; }"
+    true:
+      call: missing
+`
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(yamlRules), &rules))
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(rules.Validate(), &validationErr))
+	assert.Equal(t, "called rule set 'missing' of true branch not found or has no default condition", validationErr.Issues[0].Message)
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext)
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	require.Error(t, err)
+	assert.Equal(t, "called rule set 'missing' not found", err.Error())
+}
@@ -0,0 +1,167 @@
+package yabre
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRulesContext_InfiniteLoopTimeout(t *testing.T) {
+	yamlRules := `
+name: "infinite-loop"
+conditions:
+  loop:
+    default: true
+    check: "function() { while (true) {} }"
+    true:
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "infinite-loop.yaml")
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "infinite-loop", &rulesContext)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = runner.RunRulesContext(ctx, &rulesContext, nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRunRulesContext_InfiniteLoopInScripts(t *testing.T) {
+	yamlRules := `
+name: "infinite-scripts"
+scripts: |
+  for (;;) {}
+conditions:
+  start:
+    default: true
+    check: "function() { return true; }"
+`
+	library := createLibraryFromYAML(t, yamlRules, "infinite-scripts.yaml")
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "infinite-scripts", &rulesContext)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = runner.RunRulesContext(ctx, &rulesContext, nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestRunRulesContext_Canceled(t *testing.T) {
+	yamlRules := `
+name: "canceled"
+conditions:
+  start:
+    default: true
+    check: "function() { context.checked = true; return true; }"
+    true:
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "canceled.yaml")
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "canceled", &rulesContext)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = runner.RunRulesContext(ctx, &rulesContext, nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.NotContains(t, rulesContext, "checked")
+}
+
+func TestRunRulesContext_GoFunctionReceivesContext(t *testing.T) {
+	yamlRules := `
+name: "go-context"
+conditions:
+  start:
+    default: true
+    check: "function() { return wait(200); }"
+    true:
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "go-context.yaml")
+
+	// the function runs on its own goroutine, so it reports the context through a channel
+	received := make(chan context.Context, 2)
+	wait := func(ctx context.Context, ms int) (bool, error) {
+		received <- ctx
+		select {
+		case <-time.After(time.Duration(ms) * time.Millisecond):
+			return true, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "go-context", &rulesContext,
+		WithGoFunction[map[string]interface{}]("wait", wait))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = runner.RunRulesContext(ctx, &rulesContext, nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, ctx, <-received)
+
+	// without a deadline the function completes and gets a background context
+	_, err = runner.RunRules(&rulesContext, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, context.Background(), <-received)
+}
+
+// Go functions without a context can't be stopped, so runs wait for them rather than leaving them
+// running on the rules context after returning; run with -race
+func TestRunRulesContext_GoFunctionIgnoringContext(t *testing.T) {
+	yamlRules := `
+name: "go-slow"
+conditions:
+  start:
+    default: true
+    check: "function() { return slow(context); }"
+    true:
+      action: "function() { context.after = true; }"
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "go-slow.yaml")
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "go-slow", &rulesContext,
+		WithGoFunction[map[string]interface{}]("slow", func(m map[string]interface{}) bool {
+			time.Sleep(100 * time.Millisecond)
+			m["late"] = true
+			return true
+		}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	result, err := runner.RunRulesContext(ctx, &rulesContext, nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.GreaterOrEqual(t, time.Since(started), 100*time.Millisecond)
+
+	// the function has returned before the run, which has been interrupted right after
+	assert.Equal(t, true, rulesContext["late"])
+	assert.NotContains(t, *result, "after")
+}
@@ -1,6 +1,7 @@
 package yabre
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -115,6 +116,106 @@ conditions:
 	_, _ = runner.RunRules(&context, nil)
 }
 
+// Test circular condition references are stopped by the step budget
+func TestRunner_CircularReferencesMaxSteps(t *testing.T) {
+	yamlRules := `
+name: "circular-steps"
+conditions:
+  condition1:
+    description: "Condition 1"
+    default: true
+    check: "function() { return true; }"
+    true:
+      next: "condition2"
+  condition2:
+    description: "Condition 2"
+    check: "function() { return true; }"
+    true:
+      next: "condition1"
+`
+	library := createLibraryFromYAML(t, yamlRules, "circular-steps.yaml")
+
+	context := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "circular-steps", &context,
+		WithMaxSteps[map[string]interface{}](50))
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&context, nil)
+	require.Error(t, err)
+
+	var cycleErr *CycleError
+	require.True(t, errors.As(err, &cycleErr))
+	assert.Equal(t, 50, cycleErr.Limit)
+	assert.False(t, cycleErr.PerCondition)
+	assert.Len(t, cycleErr.Path, 3)
+	assert.Equal(t, cycleErr.Path[0], cycleErr.Path[2])
+	assert.Contains(t, err.Error(), "cycle: ")
+}
+
+// Test a condition visited too many times is reported with its cycle path
+func TestRunner_CircularReferencesMaxVisits(t *testing.T) {
+	yamlRules := `
+name: "circular-visits"
+conditions:
+  start:
+    default: true
+    check: "function() { return true; }"
+    true:
+      next: "loop"
+  loop:
+    check: "function() { context.counter = (context.counter || 0) + 1; return true; }"
+    true:
+      next: "again"
+  again:
+    check: "function() { return true; }"
+    true:
+      next: "loop"
+`
+	library := createLibraryFromYAML(t, yamlRules, "circular-visits.yaml")
+
+	context := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "circular-visits", &context,
+		WithMaxVisits[map[string]interface{}](3))
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&context, nil)
+	require.Error(t, err)
+
+	var cycleErr *CycleError
+	require.True(t, errors.As(err, &cycleErr))
+	assert.True(t, cycleErr.PerCondition)
+	assert.Equal(t, "loop", cycleErr.Condition)
+	assert.Equal(t, []string{"loop", "again", "loop"}, cycleErr.Path)
+	assert.Equal(t, int64(3), context["counter"])
+}
+
+// Test long chains of conditions don't depend on Go stack depth
+func TestRunner_LongConditionChain(t *testing.T) {
+	yamlRules := `
+name: "long-chain"
+conditions:
+  count:
+    default: true
+    check: "function() { context.counter = (context.counter || 0) + 1; return context.counter < 5000; }"
+    true:
+      next: "count"
+    false:
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "long-chain.yaml")
+
+	context := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "long-chain", &context)
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&context, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5000), context["counter"])
+
+	_, err = NewRulesRunnerFromLibrary(library, "long-chain", &context, WithMaxSteps[map[string]interface{}](-1))
+	assert.Error(t, err)
+}
+
 // Test JavaScript runtime errors
 func TestRunner_JavaScriptRuntimeError(t *testing.T) {
 	yamlRules := `
@@ -726,3 +827,227 @@ conditions:
 	assert.Equal(t, "large", context["result"])
 	assert.Less(t, duration, 5*time.Second, "Large context processing took too long")
 }
+
+// Test invalid javascript is reported when the runner is constructed
+func TestRunner_CompileErrorAtConstruction(t *testing.T) {
+	yamlRules := `
+name: "compile-error"
+conditions:
+  start:
+    default: true
+    check: "function() { return"
+    true:
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "compile-error.yaml")
+
+	context := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "compile-error", &context)
+	assert.Nil(t, runner)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to compile rules: error compiling condition function start")
+}
+
+// Test runtimes reused across runs start every run with its own context and scripts
+func TestRunner_ReusedRuntimes(t *testing.T) {
+	yamlRules := `
+name: "reused-runtimes"
+scripts: |
+  var runs = 0;
+  function seen() { return context.seen || []; }
+conditions:
+  start:
+    default: true
+    check: "function() { runs++; return runs === 1; }"
+    true:
+      action: "function() { context.seen = seen().concat([context.id]); }"
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "reused-runtimes.yaml")
+
+	initContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "reused-runtimes", &initContext)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		context := map[string]interface{}{"id": i}
+		_, err := runner.RunRules(&context, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(i)}, context["seen"])
+	}
+}
+
+// Test globals created by a run, implicitly or by the scripts, aren't seen by the next runs on a reused runtime
+func TestRunner_ReusedRuntimesGlobals(t *testing.T) {
+	yamlRules := `
+name: "reused-globals"
+scripts: |
+  var cache;
+  function cached() { return cache; }
+conditions:
+  start:
+    default: true
+    check: |
+      function() {
+        if (typeof seenBefore !== 'undefined') context.leaked = true;
+        if (cached() !== undefined) context.cached = true;
+        seenBefore = true;
+        cache = context.id;
+        globalThis.tenant = context.id;
+        return true;
+      }
+    true:
+      action: "function() { context.tenant = tenant; }"
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "reused-globals.yaml")
+
+	initContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "reused-globals", &initContext)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		context := map[string]interface{}{"id": i}
+		_, err := runner.RunRules(&context, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"id": i, "tenant": int64(i)}, context)
+	}
+}
+
+// Test scripts declaring global let, const and class bindings run again on every run
+func TestRunner_ReusedRuntimesLexicalScripts(t *testing.T) {
+	yamlRules := `
+name: "reused-lexical"
+scripts: |
+  let counter = 0;
+  const step = 1;
+  class Counter {}
+conditions:
+  start:
+    default: true
+    check: "function() { counter += step; context.counter = counter; return true; }"
+    true:
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "reused-lexical.yaml")
+
+	initContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "reused-lexical", &initContext)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		context := map[string]interface{}{}
+		_, err := runner.RunRules(&context, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), context["counter"])
+	}
+}
+
+// Test runtimes whose builtins have been replaced by the rules aren't reused
+func TestRunner_ReusedRuntimesReplacedBuiltins(t *testing.T) {
+	yamlRules := `
+name: "reused-builtins"
+conditions:
+  start:
+    default: true
+    check: |
+      function() {
+        context.replaced = typeof JSON.replaced !== 'undefined';
+        JSON = {replaced: true, stringify: JSON.stringify};
+        return true;
+      }
+    true:
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "reused-builtins.yaml")
+
+	initContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "reused-builtins", &initContext)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		context := map[string]interface{}{}
+		_, err := runner.RunRules(&context, nil)
+		require.NoError(t, err)
+		assert.Equal(t, false, context["replaced"])
+	}
+}
+
+// Stress test a runner shared across goroutines; run with -race
+func TestRunner_SharedRunnerStress(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "test", FileSystem: testFs})
+	require.NoError(t, err)
+
+	var debugCalls int64
+	var mu sync.Mutex
+	initContext := RecipeContext{}
+	aliquoting, err := NewRulesRunnerFromLibrary(rl, "aliquoting-rules", &initContext,
+		WithDebugCallback[RecipeContext](func(...interface{}) {
+			mu.Lock()
+			debugCalls++
+			mu.Unlock()
+		}))
+	require.NoError(t, err)
+
+	loanContext := LoanContext{}
+	loan, err := NewRulesRunnerFromLibrary(rl, "loan-approval", &loanContext)
+	require.NoError(t, err)
+
+	const goroutines, runs = 16, 25
+	var wg sync.WaitGroup
+	failures := make(chan string, goroutines*runs)
+
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < runs; i++ {
+				recipe := RecipeContext{
+					Container: Container{Amount: 2100},
+					OrderItems: []OrderItem{
+						{Ref: 1, Rank: 1, ProductType: "solution", Amount: 500, Concentration: 10, Solvent: "water", OrderType: "primary", State: "pending"},
+					},
+				}
+				var err error
+				switch i % 3 {
+				case 0:
+					_, err = aliquoting.RunRules(&recipe, nil)
+				case 1:
+					_, err = aliquoting.RunRulesContext(context.Background(), &recipe, nil)
+				default:
+					_, _, err = aliquoting.RunRulesWithTrace(context.Background(), &recipe, nil)
+				}
+				if err != nil || len(recipe.Products) == 0 {
+					failures <- fmt.Sprintf("aliquoting %d/%d: %v", g, i, err)
+				}
+
+				age := 17 + (g+i)%2
+				application := LoanContext{
+					Applicants: []Applicant{{Type: "primary", Age: age, Income: 5000, Debt: 1000, CreditScore: 750}},
+					LoanAmount: 20000,
+				}
+				if _, err := loan.RunRules(&application, nil); err != nil {
+					failures <- fmt.Sprintf("loan %d/%d: %v", g, i, err)
+					continue
+				}
+				expected := "approved"
+				if age < 18 {
+					expected = "rejected"
+				}
+				if application.Decision != expected {
+					failures <- fmt.Sprintf("loan %d/%d: expected %s, got %s", g, i, expected, application.Decision)
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+	close(failures)
+	for failure := range failures {
+		t.Error(failure)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, int64(goroutines*runs), debugCalls)
+}
@@ -0,0 +1,145 @@
+package yabre
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type ShippingContext struct {
+	Weight   float64
+	Express  bool
+	Method   string
+	Cost     float64
+	Tracking bool
+	Notes    []string
+}
+
+func TestRunner_ExprAndSet(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/shipping_expr.yaml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		context  ShippingContext
+		expected ShippingContext
+	}{
+		{
+			name:     "Light express package",
+			context:  ShippingContext{Weight: 200, Express: true, Notes: []string{}},
+			expected: ShippingContext{Weight: 200, Express: true, Method: "parcel", Cost: 40, Tracking: true, Notes: []string{"express"}},
+		},
+		{
+			name:     "Light package",
+			context:  ShippingContext{Weight: 200, Notes: []string{}},
+			expected: ShippingContext{Weight: 200, Method: "parcel", Cost: 20, Notes: []string{}},
+		},
+		{
+			name:     "Heavy package",
+			context:  ShippingContext{Weight: 800, Express: true, Notes: []string{}},
+			expected: ShippingContext{Weight: 800, Express: true, Method: "freight", Cost: 120, Notes: []string{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := NewRulesRunnerFromYaml(yamlFile, &tt.context)
+			require.NoError(t, err)
+
+			result, err := runner.RunRules(&tt.context, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, *result)
+		})
+	}
+}
+
+func TestRunner_SetNestedFields(t *testing.T) {
+	yamlRules := `
+name: "nested-set"
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      set:
+        order.status: approved
+        order.tags: [new, priority]
+        order.limits: {daily: 10}
+        order.reviewer: null
+`
+	rulesContext := map[string]interface{}{"order": map[string]interface{}{}}
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext)
+	require.NoError(t, err)
+
+	result, err := runner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"status":   "approved",
+		"tags":     []interface{}{"new", "priority"},
+		"limits":   map[string]interface{}{"daily": int64(10)},
+		"reviewer": nil,
+	}, (*result)["order"])
+}
+
+func TestRules_ExprAndSetErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected string
+	}{
+		{
+			name: "Check and expr",
+			yaml: `
+conditions:
+  start:
+    check: "function() { return true; }"
+    expr: "true"
+`,
+			expected: "condition start can't have both check and expr",
+		},
+		{
+			name: "Invalid field",
+			yaml: `
+conditions:
+  start:
+    expr: "true"
+    true:
+      set:
+        "order[0]": 1
+`,
+			expected: "invalid set: invalid field 'order[0]'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules Rules
+			err := yaml.Unmarshal([]byte(tt.yaml), &rules)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestValidate_InvalidExpr(t *testing.T) {
+	yamlRules := `
+name: "invalid-expr"
+conditions:
+  start:
+    default: true
+    expr: "context.Weight <"
+    true:
+      set:
+        Cost: "= context.Weight *"
+`
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(yamlRules), &rules))
+
+	err := rules.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "condition 'start': invalid expr: ")
+	assert.Contains(t, err.Error(), "condition 'start': invalid true set: ")
+}
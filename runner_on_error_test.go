@@ -0,0 +1,253 @@
+package yabre
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type RatesContext struct {
+	Currency  string
+	Amount    float64
+	Rate      float64
+	Converted float64
+	RateError string
+	Errors    []string
+}
+
+// newRatesRunner returns a runner whose rates service fails the given number of times before returning a rate
+func newRatesRunner(t *testing.T, rates *RatesContext, failures int) *RulesRunner[RatesContext] {
+	yamlFile, err := os.ReadFile("test/rates_on_error.yaml")
+	require.NoError(t, err)
+
+	calls := 0
+	runner, err := NewRulesRunnerFromYaml(yamlFile, rates,
+		WithGoFunction[RatesContext]("fetchRate", func(currency string) (float64, error) {
+			calls++
+			if calls <= failures {
+				return 0, errors.New("rates service unavailable")
+			}
+			return 2, nil
+		}))
+	require.NoError(t, err)
+	return runner
+}
+
+func TestRunner_OnErrorRetry(t *testing.T) {
+	rates := &RatesContext{Currency: "EUR", Amount: 10, Errors: []string{}}
+	runner := newRatesRunner(t, rates, 2)
+
+	result, trace, err := runner.RunRulesWithTrace(context.Background(), rates, nil)
+	require.NoError(t, err)
+
+	// the third attempt succeeds
+	assert.Equal(t, 2.0, result.Rate)
+	assert.Equal(t, 20.0, result.Converted)
+	assert.Empty(t, result.RateError)
+	assert.Equal(t, []string{"fetch_rate", "fetch_rate", "fetch_rate", "convert"}, trace.Path())
+
+	retries := 0
+	for _, event := range trace.Events {
+		if event.Type == TraceRetry {
+			retries++
+			assert.Equal(t, "fetch_rate_on_error", event.Decision)
+			assert.Contains(t, event.Error, "rates service unavailable")
+		}
+	}
+	assert.Equal(t, 2, retries)
+}
+
+func TestRunner_OnErrorRecovery(t *testing.T) {
+	rates := &RatesContext{Currency: "EUR", Amount: 10, Errors: []string{}}
+	runner := newRatesRunner(t, rates, 3)
+
+	result, trace, err := runner.RunRulesWithTrace(context.Background(), rates, nil)
+	require.NoError(t, err)
+
+	// the error is recorded on the context and the run recovers with the default rate
+	assert.Contains(t, result.RateError, "error evaluating check function")
+	assert.Contains(t, result.RateError, "rates service unavailable")
+	assert.Equal(t, 1.0, result.Rate)
+	assert.Equal(t, 10.0, result.Converted)
+	assert.Equal(t, []string{"fetch_rate", "fetch_rate", "fetch_rate", "use_default_rate", "convert"}, trace.Path())
+
+	handled := []TraceEvent{}
+	for _, event := range trace.Events {
+		if event.Type == TraceOnError {
+			handled = append(handled, event)
+		}
+	}
+	require.Len(t, handled, 1)
+	assert.Equal(t, "fetch_rate", handled[0].Condition)
+	assert.Equal(t, "fetch_rate_on_error", handled[0].Decision)
+}
+
+func TestRunner_OnErrorRulesFallback(t *testing.T) {
+	rates := &RatesContext{Currency: "EUR", Amount: -1, Errors: []string{}}
+	runner := newRatesRunner(t, rates, 0)
+
+	result, err := runner.RunRules(rates, nil)
+	require.NoError(t, err)
+
+	// the thrown error is exposed to the on_error decision of the rules
+	assert.Equal(t, []string{"convert: Error: negative amount"}, result.Errors)
+	assert.Zero(t, result.Converted)
+}
+
+func TestRunner_OnErrorFailingHandler(t *testing.T) {
+	yamlRules := `
+name: failing-handler
+conditions:
+  start:
+    default: true
+    check: "function() { throw new Error('check failed'); }"
+    true:
+      terminate: true
+    on_error:
+      action: "function(error) { throw new Error('handler failed'); }"
+`
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext)
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "check failed")
+	assert.Contains(t, err.Error(), "handler failed")
+}
+
+func TestRunner_OnErrorIgnoresCycles(t *testing.T) {
+	yamlRules := `
+name: cycle
+on_error:
+  terminate: true
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      next: start
+`
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext, WithMaxSteps[map[string]interface{}](10))
+	require.NoError(t, err)
+
+	_, err = runner.RunRules(&rulesContext, nil)
+	var cycleErr *CycleError
+	assert.True(t, errors.As(err, &cycleErr), err)
+}
+
+func TestValidate_OnError(t *testing.T) {
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: on-error
+on_error:
+  next: recover
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      retry: 1
+      terminate: true
+    on_error:
+      next: missing
+  recover:
+    expr: "true"
+    true:
+      terminate: true
+`), &rules))
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(rules.Validate(), &validationErr))
+	messages := []string{}
+	for _, issue := range validationErr.Issues {
+		messages = append(messages, issue.String())
+	}
+	// recover is reachable through the on_error decision of the rules
+	assert.Equal(t, []string{
+		"condition 'start': next condition 'missing' of on_error branch not found",
+		"condition 'start': retry of true branch is only supported by on_error",
+	}, messages)
+
+	var decision Decision
+	assert.Error(t, yaml.Unmarshal([]byte("retry: -1"), &decision))
+}
+
+func TestRunner_OnErrorOfCalledRuleSet(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"main.yaml": {Data: []byte(`
+name: main
+require:
+  - rates
+on_error:
+  set:
+    handled_by: main
+  terminate: true
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      call: rates
+      next: finish
+  finish:
+    expr: "context.rate > 0"
+    true:
+      set:
+        status: converted
+      terminate: true
+`)},
+		"rates.yaml": {Data: []byte(`
+name: rates
+namespaced: true
+on_error:
+  set:
+    handled_by: rates
+  next: use_default
+conditions:
+  fetch:
+    default: true
+    check: "function() { throw new Error('rates service unavailable'); }"
+    true:
+      terminate: true
+  use_default:
+    expr: "true"
+    true:
+      set:
+        rate: 1
+`)},
+	}
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+	require.NoError(t, rl.ValidateAll())
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(rl, "main", &rulesContext)
+	require.NoError(t, err)
+
+	result, trace, err := runner.RunRulesWithTrace(context.Background(), &rulesContext, nil)
+	require.NoError(t, err)
+
+	// the failure of the called rule set is handled by its own on_error, which recovers within the rule set
+	assert.Equal(t, "rates", (*result)["handled_by"])
+	assert.Equal(t, "converted", (*result)["status"])
+	assert.Equal(t, []string{"start", "rates.fetch", "rates.use_default", "finish"}, trace.Path())
+
+	handled := []TraceEvent{}
+	for _, event := range trace.Events {
+		if event.Type == TraceOnError {
+			handled = append(handled, event)
+		}
+	}
+	require.Len(t, handled, 1)
+	assert.Equal(t, "rates.fetch", handled[0].Condition)
+	assert.Equal(t, "rates.on_error", handled[0].Decision)
+}
@@ -0,0 +1,157 @@
+package yabre
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RuleSource provides the rule files of a library: YAML rule sets and DMN models, told apart by the extension of their paths
+type RuleSource interface {
+	// List returns the paths of the rule files
+	List(ctx context.Context) ([]string, error)
+	// Read returns the content of a rule file
+	Read(ctx context.Context, path string) ([]byte, error)
+	// Watch returns a channel signalling changes of the rule files until ctx is done,
+	// or nil if the source can't notify changes and has to be polled
+	Watch(ctx context.Context) (<-chan struct{}, error)
+}
+
+// isRuleFile reports whether the path is a YAML rules file or a DMN model
+func isRuleFile(path string) bool {
+	return strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") || strings.HasSuffix(path, ".dmn")
+}
+
+// fsSource reads the rule files under the base path of a file system
+type fsSource struct {
+	fileSystem fs.FS
+	basePath   string
+}
+
+// newFSSource creates a source reading the rule files under basePath of the file system,
+// or of the OS file system at basePath if fileSystem is nil
+func newFSSource(fileSystem fs.FS, basePath string) *fsSource {
+	if basePath == "" {
+		basePath = "."
+	}
+
+	if fileSystem == nil {
+		fileSystem = os.DirFS(basePath)
+		// BasePath becomes relative to fileSystem root
+		basePath = "."
+	} else if _, ok := fileSystem.(embed.FS); ok {
+		// For embedded fs, directory walkthru fs.WalkDir doesn't like paths starting with ./ so we trim it
+		basePath = strings.TrimPrefix(basePath, "./")
+	}
+	return &fsSource{fileSystem: fileSystem, basePath: basePath}
+}
+
+func (s *fsSource) List(ctx context.Context) ([]string, error) {
+	paths := []string{}
+	err := fs.WalkDir(s.fileSystem, s.basePath, func(path string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isRuleFile(path) {
+			paths = append(paths, path)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func (s *fsSource) Read(ctx context.Context, path string) ([]byte, error) {
+	return fs.ReadFile(s.fileSystem, path)
+}
+
+func (s *fsSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return nil, nil
+}
+
+// MemorySource holds rule files in memory, keyed by path. It notifies watchers when files are set or deleted.
+type MemorySource struct {
+	mu       sync.RWMutex
+	files    map[string][]byte
+	watchers map[chan struct{}]bool
+}
+
+// NewMemorySource creates a source holding a copy of the files, keyed by path
+func NewMemorySource(files map[string][]byte) *MemorySource {
+	s := &MemorySource{files: make(map[string][]byte, len(files)), watchers: map[chan struct{}]bool{}}
+	for path, data := range files {
+		s.files[path] = append([]byte(nil), data...)
+	}
+	return s
+}
+
+// Set adds or replaces the file at path
+func (s *MemorySource) Set(path string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = append([]byte(nil), data...)
+	s.notify()
+}
+
+// Delete removes the file at path
+func (s *MemorySource) Delete(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, path)
+	s.notify()
+}
+
+// notify signals the watchers without blocking, a pending signal covers later changes too
+func (s *MemorySource) notify() {
+	for watcher := range s.watchers {
+		select {
+		case watcher <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *MemorySource) List(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	paths := make([]string, 0, len(s.files))
+	for path := range s.files {
+		if isRuleFile(path) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (s *MemorySource) Read(ctx context.Context, path string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.files[path]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", path)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *MemorySource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	watcher := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.watchers[watcher] = true
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.watchers, watcher)
+	}()
+	return watcher, nil
+}
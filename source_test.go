@@ -0,0 +1,111 @@
+package yabre
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestRulesLibrary_MemorySource(t *testing.T) {
+	source := NewMemorySource(map[string][]byte{
+		"pricing.yaml": []byte(pricingRules("1")),
+		"README.md":    []byte("not a rule file"),
+	})
+	rl, err := NewRulesLibrary(RulesLibrarySettings{Source: source})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pricing": "pricing.yaml"}, rl.GetRuleNamesAndPaths())
+	assert.Equal(t, 1.0, runPricing(t, rl))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan error, 10)
+	watching := make(chan error)
+	go func() {
+		// the interval is only used to poll sources that don't notify changes
+		watching <- rl.Watch(ctx, time.Hour, func(err error) { reloads <- err })
+	}()
+
+	// the watcher registers asynchronously, so the change is repeated until it is noticed
+	deadline := time.After(5 * time.Second)
+	for reloaded := false; !reloaded; {
+		source.Set("pricing.yaml", []byte(pricingRules("2")))
+		select {
+		case err := <-reloads:
+			require.NoError(t, err)
+			reloaded = true
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("library not reloaded")
+		}
+	}
+	assert.Equal(t, 2.0, runPricing(t, rl))
+
+	source.Delete("pricing.yaml")
+	select {
+	case err := <-reloads:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("library not reloaded")
+	}
+	_, err = rl.LoadRules("pricing")
+	assert.ErrorContains(t, err, "rule set pricing not found")
+
+	cancel()
+	assert.NoError(t, <-watching)
+}
+
+func TestRulesLibrary_SQLSource(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "rules.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE rules (name TEXT PRIMARY KEY, body BLOB)")
+	require.NoError(t, err)
+	insert := func(path, content string) {
+		_, err := db.Exec("INSERT OR REPLACE INTO rules (name, body) VALUES (?, ?)", path, []byte(content))
+		require.NoError(t, err)
+	}
+	insert("pricing.yaml", pricingRules("1.5"))
+	insert("rates/v1.yaml", ratesRules("1.0.0", "1"))
+	insert("notes/todo.txt", "not a rule file")
+
+	_, err = NewSQLSource(SQLSourceSettings{})
+	assert.EqualError(t, err, "no database")
+	_, err = NewSQLSource(SQLSourceSettings{DB: db, Table: "rules; DROP TABLE rules"})
+	assert.EqualError(t, err, "invalid SQL identifier rules; DROP TABLE rules")
+	_, err = NewSQLSource(SQLSourceSettings{DB: db, Placeholder: "'x' OR 1=1"})
+	assert.EqualError(t, err, "invalid SQL placeholder 'x' OR 1=1")
+
+	source, err := NewSQLSource(SQLSourceSettings{DB: db, Table: "main.rules", PathColumn: "name", ContentColumn: "body", Placeholder: "$1"})
+	require.NoError(t, err)
+
+	paths, err := source.List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pricing.yaml", "rates/v1.yaml"}, paths)
+	_, err = source.Read(context.Background(), "missing.yaml")
+	assert.EqualError(t, err, "file missing.yaml not found")
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{Source: source})
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, runPricing(t, rl))
+	assert.Equal(t, []string{"1.0.0"}, rl.Versions("rates"))
+
+	insert("pricing.yaml", pricingRules("2.5"))
+	require.NoError(t, rl.Reload())
+	assert.Equal(t, 2.5, runPricing(t, rl))
+
+	// the default table and columns, with text content
+	_, err = db.Exec("CREATE TABLE rule_files (path TEXT PRIMARY KEY, content TEXT)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO rule_files (path, content) VALUES (?, ?)", "pricing.yaml", pricingRules("3.5"))
+	require.NoError(t, err)
+	source, err = NewSQLSource(SQLSourceSettings{DB: db})
+	require.NoError(t, err)
+	rl, err = NewRulesLibrary(RulesLibrarySettings{Source: source})
+	require.NoError(t, err)
+	assert.Equal(t, 3.5, runPricing(t, rl))
+}
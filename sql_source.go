@@ -0,0 +1,109 @@
+package yabre
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// SQLSourceSettings configures the table a SQLSource reads rule files from
+type SQLSourceSettings struct {
+	// DB is the database holding the rule files
+	DB *sql.DB
+	// Table holding one row per rule file, `rule_files` by default
+	Table string
+	// PathColumn holds the path of the rule file, `path` by default. Its extension tells YAML rule sets and DMN models apart.
+	PathColumn string
+	// ContentColumn holds the content of the rule file, as text or binary, `content` by default
+	ContentColumn string
+	// Placeholder is the query parameter placeholder of the database driver, `?` by default, `$1` for PostgreSQL,
+	// `@p1` for SQL Server or `:path` for Oracle
+	Placeholder string
+}
+
+// SQLSource reads rule files from a database table through database/sql. It can't notify changes, so watching libraries poll it.
+type SQLSource struct {
+	db        *sql.DB
+	listQuery string
+	readQuery string
+}
+
+var sqlIdentifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// sqlPlaceholderRegex matches the placeholders of the common drivers: `?`, `?1`, `$1`, `:path` and `@p1`
+var sqlPlaceholderRegex = regexp.MustCompile(`^(\?[0-9]*|[$:@][A-Za-z0-9_]+)$`)
+
+func NewSQLSource(s SQLSourceSettings) (*SQLSource, error) {
+	if s.DB == nil {
+		return nil, errors.New("no database")
+	}
+	if s.Table == "" {
+		s.Table = "rule_files"
+	}
+	if s.PathColumn == "" {
+		s.PathColumn = "path"
+	}
+	if s.ContentColumn == "" {
+		s.ContentColumn = "content"
+	}
+	if s.Placeholder == "" {
+		s.Placeholder = "?"
+	}
+
+	// identifiers and the placeholder can't be query parameters, so they are checked before being put into the queries
+	for _, identifier := range []string{s.Table, s.PathColumn, s.ContentColumn} {
+		if !sqlIdentifierRegex.MatchString(identifier) {
+			return nil, fmt.Errorf("invalid SQL identifier %s", identifier)
+		}
+	}
+	if !sqlPlaceholderRegex.MatchString(s.Placeholder) {
+		return nil, fmt.Errorf("invalid SQL placeholder %s", s.Placeholder)
+	}
+
+	return &SQLSource{
+		db:        s.DB,
+		listQuery: fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", s.PathColumn, s.Table, s.PathColumn),
+		readQuery: fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s", s.ContentColumn, s.Table, s.PathColumn, s.Placeholder),
+	}, nil
+}
+
+func (s *SQLSource) List(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.listQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rule files: %w", err)
+	}
+	defer rows.Close()
+
+	paths := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to list rule files: %w", err)
+		}
+		if isRuleFile(path) {
+			paths = append(paths, path)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list rule files: %w", err)
+	}
+	return paths, nil
+}
+
+func (s *SQLSource) Read(ctx context.Context, path string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, s.readQuery, path).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("file %s not found", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *SQLSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return nil, nil
+}
@@ -0,0 +1,275 @@
+package yabre
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// HitPolicy decides which matching rows of a decision table set the outputs
+type HitPolicy string
+
+const (
+	// HitUnique expects at most one row to match and fails the run otherwise; it is the default hit policy
+	HitUnique HitPolicy = "unique"
+	// HitAny expects all matching rows to have the same outputs and fails the run otherwise
+	HitAny HitPolicy = "any"
+	// HitFirst takes the first matching row
+	HitFirst HitPolicy = "first"
+	// HitPriority takes the matching row with the highest priority, the first one on ties
+	HitPriority HitPolicy = "priority"
+	// HitCollect takes all matching rows and sets every output to the list of their values
+	HitCollect HitPolicy = "collect"
+)
+
+// DecisionTable maps inputs to outputs through rows of predicates.
+// A condition with a `table` evaluates the table, sets the outputs of the matching rows
+// and takes its true branch if any row matched, its false branch otherwise.
+type DecisionTable struct {
+	Name        string       `yaml:"-"`
+	Description string       `yaml:"description,omitempty"`
+	HitPolicy   HitPolicy    `yaml:"hit_policy,omitempty"`
+	Inputs      []TableInput `yaml:"inputs"`
+	// Outputs are the context fields set by the table as dotted paths relative to the context
+	Outputs []string   `yaml:"outputs"`
+	Rows    []TableRow `yaml:"rows"`
+}
+
+// TableInput is a column of predicates of a decision table
+type TableInput struct {
+	// Name of the input, available to `=` expressions of the table
+	Name string `yaml:"name"`
+	// Expr is the javascript expression of the input value
+	Expr string `yaml:"expr"`
+}
+
+// TableRow holds a predicate for every input and a value for every output of a decision table.
+//
+// A predicate is `-` or empty to match any value, a comparison such as `>= 1000`, a javascript expression
+// of `value` starting with `=` such as `= value > 1 && value < 5`, or a literal compared with `===`.
+// Output values are literals, or javascript expressions when they are strings starting with `=`.
+type TableRow struct {
+	When []interface{} `yaml:"when"`
+	Then []interface{} `yaml:"then"`
+	// Priority of the row for the priority hit policy
+	Priority int `yaml:"priority,omitempty"`
+}
+
+var (
+	comparisonRegex = regexp.MustCompile(`^(<=|>=|<|>|===|!==|==|!=)`)
+	identifierRegex = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+)
+
+// tableFunction turns a decision table into a check function
+// that sets the outputs of the matching rows and returns whether any row matched
+func tableFunction(table *DecisionTable) (string, error) {
+	var code strings.Builder
+	code.WriteString("function() {\n")
+
+	for i, input := range table.Inputs {
+		if !identifierRegex.MatchString(input.Name) || input.Name == "context" {
+			return "", fmt.Errorf("input %d: name '%s' should be a javascript identifier other than 'context'", i+1, input.Name)
+		}
+		if strings.TrimSpace(input.Expr) == "" {
+			return "", fmt.Errorf("input %s: expr is missing", input.Name)
+		}
+		fmt.Fprintf(&code, "var %s = (%s\n);\n", input.Name, input.Expr)
+	}
+
+	for _, output := range table.Outputs {
+		if !fieldPathRegex.MatchString(output) {
+			return "", fmt.Errorf("invalid output field '%s'", output)
+		}
+	}
+
+	code.WriteString("var __rows = [];\n")
+	for i, row := range table.Rows {
+		if len(row.When) != len(table.Inputs) {
+			return "", fmt.Errorf("row %d: %d predicates for %d inputs", i+1, len(row.When), len(table.Inputs))
+		}
+		if len(row.Then) != len(table.Outputs) {
+			return "", fmt.Errorf("row %d: %d values for %d outputs", i+1, len(row.Then), len(table.Outputs))
+		}
+
+		tests := []string{"true"}
+		for j, predicate := range row.When {
+			test, err := predicateTest(predicate)
+			if err != nil {
+				return "", fmt.Errorf("row %d: invalid predicate of input %s: %w", i+1, table.Inputs[j].Name, err)
+			}
+			if test != "" {
+				tests = append(tests, fmt.Sprintf("(function(value) { return (%s\n); })(%s)", test, table.Inputs[j].Name))
+			}
+		}
+
+		values := make([]string, len(row.Then))
+		for j, value := range row.Then {
+			js, err := jsValue(value)
+			if err != nil {
+				return "", fmt.Errorf("row %d: invalid value of output %s: %w", i+1, table.Outputs[j], err)
+			}
+			values[j] = js
+		}
+
+		fmt.Fprintf(&code, "if (%s) __rows.push({ row: %d, priority: %d, values: function() { return [%s]; } });\n",
+			strings.Join(tests, " && "), i+1, row.Priority, strings.Join(values, ", "))
+	}
+
+	code.WriteString("if (__rows.length === 0) return false;\n")
+
+	switch table.HitPolicy {
+	case HitUnique, "":
+		fmt.Fprintf(&code, "if (__rows.length > 1) throw new Error(%s + __rows.map(function(r) { return r.row; }).join(', '));\n",
+			jsString(fmt.Sprintf("table %s has unique hit policy but several rows match: ", table.Name)))
+		code.WriteString("var __values = __rows[0].values();\n")
+	case HitAny:
+		code.WriteString("var __values = __rows[0].values();\n")
+		code.WriteString("var __differing = __rows.filter(function(r) { return JSON.stringify(r.values()) !== JSON.stringify(__values); });\n")
+		fmt.Fprintf(&code, "if (__differing.length > 0) throw new Error(%s + [__rows[0]].concat(__differing).map(function(r) { return r.row; }).join(', '));\n",
+			jsString(fmt.Sprintf("table %s has any hit policy but matching rows have different outputs: ", table.Name)))
+	case HitFirst:
+		code.WriteString("var __values = __rows[0].values();\n")
+	case HitPriority:
+		code.WriteString("var __values = __rows.reduce(function(a, b) { return b.priority > a.priority ? b : a; }).values();\n")
+	case HitCollect:
+		code.WriteString("var __all = __rows.map(function(r) { return r.values(); });\n")
+		code.WriteString("var __values = [];\n")
+		for j := range table.Outputs {
+			fmt.Fprintf(&code, "__values.push(__all.map(function(v) { return v[%d]; }));\n", j)
+		}
+	default:
+		return "", fmt.Errorf("unknown hit policy '%s'", table.HitPolicy)
+	}
+
+	for j, output := range table.Outputs {
+		fmt.Fprintf(&code, "context.%s = __values[%d];\n", output, j)
+	}
+	code.WriteString("return true;\n}")
+
+	return code.String(), nil
+}
+
+// predicateTest returns the javascript test of `value` for a predicate of a decision table, or "" for any value
+func predicateTest(predicate interface{}) (string, error) {
+	s, ok := predicate.(string)
+	if !ok {
+		if predicate == nil {
+			return "", nil
+		}
+		literal, err := json.Marshal(jsonValue(predicate))
+		if err != nil {
+			return "", err
+		}
+		return "value === " + string(literal), nil
+	}
+
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "" || s == "-":
+		return "", nil
+	case strings.HasPrefix(s, "="):
+		return strings.TrimPrefix(s, "="), nil
+	case comparisonRegex.MatchString(s):
+		return "value " + s, nil
+	}
+	return "value === " + jsString(s), nil
+}
+
+func jsString(s string) string {
+	literal, _ := json.Marshal(s)
+	return string(literal)
+}
+
+// Markdown renders the decision table as a markdown table with a column per input and output
+func (t *DecisionTable) Markdown() string {
+	var md strings.Builder
+
+	fmt.Fprintf(&md, "### %s\n\n", t.Name)
+	if t.Description != "" {
+		fmt.Fprintf(&md, "%s\n\n", t.Description)
+	}
+	fmt.Fprintf(&md, "Hit policy: %s\n\n", ifEmpty(string(t.HitPolicy), string(HitUnique)))
+
+	header := []string{"#"}
+	for _, input := range t.Inputs {
+		header = append(header, fmt.Sprintf("%s (`%s`)", input.Name, markdownCell(input.Expr)))
+	}
+	for _, output := range t.Outputs {
+		header = append(header, "→ "+output)
+	}
+	if t.HitPolicy == HitPriority {
+		header = append(header, "priority")
+	}
+	fmt.Fprintf(&md, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(&md, "|%s\n", strings.Repeat("---|", len(header)))
+
+	for i, row := range t.Rows {
+		cells := []string{fmt.Sprint(i + 1)}
+		for _, predicate := range row.When {
+			cells = append(cells, markdownValue(predicate))
+		}
+		for _, value := range row.Then {
+			cells = append(cells, markdownValue(value))
+		}
+		if t.HitPolicy == HitPriority {
+			cells = append(cells, fmt.Sprint(row.Priority))
+		}
+		fmt.Fprintf(&md, "| %s |\n", strings.Join(cells, " | "))
+	}
+
+	return md.String()
+}
+
+func markdownValue(value interface{}) string {
+	if value == nil {
+		return "-"
+	}
+	if s, ok := value.(string); ok {
+		return markdownCell(ifEmpty(s, "-"))
+	}
+	literal, err := json.Marshal(jsonValue(value))
+	if err != nil {
+		return markdownCell(fmt.Sprint(value))
+	}
+	return markdownCell(string(literal))
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", "\\|"), "\n", " ")
+}
+
+// ExportMarkdownTablesFromLibrary renders the decision tables of a rule set and its dependencies as markdown
+func ExportMarkdownTablesFromLibrary(library *RulesLibrary, ruleName string) (string, error) {
+	rules, err := library.LoadRules(ruleName)
+	if err != nil {
+		return "", fmt.Errorf("failed to load rules: %w", err)
+	}
+	return rules.markdownTables(), nil
+}
+
+// ExportMarkdownTables renders the decision tables of rules as markdown, ordered by table name
+func ExportMarkdownTables(yamlString []byte) (string, error) {
+	var rules Rules
+	if err := yaml.Unmarshal(yamlString, &rules); err != nil {
+		return "", fmt.Errorf("error parsing YAML: %w", err)
+	}
+	return rules.markdownTables(), nil
+}
+
+func (r *Rules) markdownTables() string {
+	names := make([]string, 0, len(r.Tables))
+	for name := range r.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tables := make([]string, len(names))
+	for i, name := range names {
+		tables[i] = r.Tables[name].Markdown()
+	}
+	return strings.Join(tables, "\n")
+}
@@ -0,0 +1,275 @@
+package yabre
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type PricingContext struct {
+	CustomerType string
+	Total        float64
+	Express      bool
+	Country      string
+	Discount     float64
+	Reason       string
+	Surcharges   []string
+}
+
+func TestDecisionTable_Run(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/pricing_tables.yaml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		context            PricingContext
+		expectedDiscount   float64
+		expectedReason     string
+		expectedSurcharges []string
+	}{
+		{
+			name:               "Large business order",
+			context:            PricingContext{CustomerType: "business", Total: 1200, Country: "US"},
+			expectedDiscount:   0.15,
+			expectedReason:     "large business order",
+			expectedSurcharges: []string{},
+		},
+		{
+			name:               "Business order",
+			context:            PricingContext{CustomerType: "business", Total: 100, Express: true, Country: "US"},
+			expectedDiscount:   0.1,
+			expectedReason:     "business order",
+			expectedSurcharges: []string{"express"},
+		},
+		{
+			name:               "Consumer order",
+			context:            PricingContext{CustomerType: "consumer", Total: 600, Express: true, Country: "FR"},
+			expectedDiscount:   0.05,
+			expectedReason:     "order over 500",
+			expectedSurcharges: []string{"express", "international"},
+		},
+		{
+			name:               "No discount",
+			context:            PricingContext{CustomerType: "consumer", Total: 100, Country: "FR"},
+			expectedDiscount:   0,
+			expectedReason:     "no discount",
+			expectedSurcharges: []string{"international"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := NewRulesRunnerFromYaml(yamlFile, &tt.context)
+			require.NoError(t, err)
+
+			result, err := runner.RunRules(&tt.context, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedDiscount, result.Discount)
+			assert.Equal(t, tt.expectedReason, result.Reason)
+			assert.Equal(t, tt.expectedSurcharges, result.Surcharges)
+		})
+	}
+}
+
+func TestDecisionTable_HitPolicies(t *testing.T) {
+	yamlRules := `
+name: "hit-policies"
+tables:
+  tier:
+    hit_policy: %s
+    inputs:
+      - name: points
+        expr: context.points
+    outputs: [tier]
+    rows:
+      - when: [">= 100"]
+        then: [gold]
+      - when: [">= 50"]
+        then: [silver]
+        priority: 2
+      - when: ["-"]
+        then: [bronze]
+        priority: 1
+conditions:
+  start:
+    default: true
+    table: tier
+    true:
+      terminate: true
+`
+	tests := []struct {
+		policy   HitPolicy
+		points   int
+		expected interface{}
+		err      string
+	}{
+		{policy: HitFirst, points: 150, expected: "gold"},
+		{policy: HitFirst, points: 10, expected: "bronze"},
+		{policy: HitPriority, points: 150, expected: "silver"},
+		{policy: HitPriority, points: 10, expected: "bronze"},
+		{policy: HitCollect, points: 60, expected: []interface{}{"silver", "bronze"}},
+		{policy: HitUnique, points: 60, err: "table tier has unique hit policy but several rows match: 2, 3"},
+		{policy: HitAny, points: 10, expected: "bronze"},
+		{policy: HitAny, points: 60, err: "table tier has any hit policy but matching rows have different outputs: 2, 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			rulesContext := map[string]interface{}{"points": tt.points}
+			runner, err := NewRulesRunnerFromYaml([]byte(fmt.Sprintf(yamlRules, tt.policy)), &rulesContext)
+			require.NoError(t, err)
+
+			result, err := runner.RunRules(&rulesContext, nil)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, (*result)["tier"])
+		})
+	}
+}
+
+func TestDecisionTable_Validate(t *testing.T) {
+	yamlRules := `
+name: "invalid-tables"
+tables:
+  cells:
+    inputs:
+      - name: a
+        expr: context.a
+    outputs: [b]
+    rows:
+      - when: [1, 2]
+        then: [3]
+  policy:
+    hit_policy: random
+    inputs:
+      - name: a
+        expr: context.a
+    outputs: [b]
+conditions:
+  start:
+    default: true
+    table: missing
+    true:
+      terminate: true
+`
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(yamlRules), &rules))
+
+	err := rules.Validate()
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []ValidationIssue{
+		{Message: "table 'cells': row 1: 2 predicates for 1 inputs"},
+		{Message: "table 'policy': unknown hit policy 'random'"},
+		{Condition: "start", Message: "table 'missing' not found"},
+	}, validationErr.Issues)
+
+	rulesContext := map[string]interface{}{}
+	_, err = NewRulesRunnerFromYaml([]byte(yamlRules), &rulesContext)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "table missing of condition start not found")
+}
+
+func TestDecisionTable_Markdown(t *testing.T) {
+	yamlFile, err := os.ReadFile("test/pricing_tables.yaml")
+	require.NoError(t, err)
+
+	markdown, err := ExportMarkdownTables(yamlFile)
+	require.NoError(t, err)
+
+	assert.Contains(t, markdown, "### discount\n\nDiscount by customer type and order total\n\nHit policy: first\n\n")
+	assert.Contains(t, markdown, "| # | customer (`context.CustomerType`) | total (`context.Total`) | → Discount | → Reason |\n|---|---|---|---|---|\n")
+	assert.Contains(t, markdown, "| 1 | business | >= 1000 | 0.15 | large business order |\n")
+	assert.Contains(t, markdown, "| 3 | - | = value >= 500 && value < 1000 | 0.05 | = 'order over ' + 500 |\n")
+	assert.Contains(t, markdown, "### surcharge")
+	assert.Contains(t, markdown, "| 1 | true | - | express |\n")
+}
+
+func TestDecisionTable_Library(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "./test"})
+	require.NoError(t, err)
+
+	markdown, err := ExportMarkdownTablesFromLibrary(rl, "pricing-tables")
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "### discount")
+
+	mermaid, err := ExportMermaidFromLibrary(rl, "pricing-tables", "apply_discount")
+	require.NoError(t, err)
+	assert.Contains(t, mermaid, "apply_discount_table[/\"`table discount`\"/]")
+	assert.Contains(t, mermaid, "apply_discount -.- apply_discount_table")
+}
+
+func TestDecisionTable_NamespacedRuleSet(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"main.yaml": {Data: []byte(`
+name: main
+require:
+  - shipping
+tables:
+  rate:
+    inputs:
+      - name: weight
+        expr: context.weight
+    outputs: [rate]
+    rows:
+      - when: ["-"]
+        then: [1]
+conditions:
+  start:
+    default: true
+    table: rate
+    true:
+      next: shipping.start
+`)},
+		"shipping.yaml": {Data: []byte(`
+name: shipping
+namespaced: true
+tables:
+  rate:
+    inputs:
+      - name: weight
+        expr: context.weight
+    outputs: [shipping]
+    rows:
+      - when: ["< 10"]
+        then: [5]
+      - when: [">= 10"]
+        then: [20]
+conditions:
+  start:
+    table: rate
+    true:
+      terminate: true
+`)},
+	}
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+
+	rules, err := rl.LoadRules("main")
+	require.NoError(t, err)
+	assert.Contains(t, rules.Tables, "rate")
+	assert.Contains(t, rules.Tables, "shipping.rate")
+	assert.Equal(t, "shipping.rate", rules.Conditions["shipping.start"].Table)
+
+	rulesContext := map[string]interface{}{"weight": 12}
+	runner, err := NewRulesRunnerFromLibrary(rl, "main", &rulesContext)
+	require.NoError(t, err)
+
+	result, err := runner.RunRules(&rulesContext, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, (*result)["rate"])
+	assert.EqualValues(t, 20, (*result)["shipping"])
+}
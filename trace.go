@@ -0,0 +1,115 @@
+package yabre
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TraceEventType is the type of an event recorded in a Trace
+type TraceEventType string
+
+const (
+	// TraceCondition is recorded when a condition is about to be evaluated
+	TraceCondition TraceEventType = "condition"
+	// TraceResult is recorded with the result of a check function
+	TraceResult TraceEventType = "result"
+	// TraceAction is recorded when an action function has run
+	TraceAction TraceEventType = "action"
+	// TraceNext is recorded when the execution moves to the next condition
+	TraceNext TraceEventType = "next"
+	// TraceTerminate is recorded when the execution terminates
+	TraceTerminate TraceEventType = "terminate"
+	// TraceError is recorded when the execution fails
+	TraceError TraceEventType = "error"
+	// TraceCall is recorded when a decision calls another rule set
+	TraceCall TraceEventType = "call"
+	// TraceReturn is recorded when a called rule set has finished and the execution returns to the caller
+	TraceReturn TraceEventType = "return"
+	// TraceRetry is recorded when a failing condition is evaluated again by its on_error decision
+	TraceRetry TraceEventType = "retry"
+	// TraceOnError is recorded when the error of a failing condition is handled by an on_error decision
+	TraceOnError TraceEventType = "on_error"
+)
+
+// CallFrame is a rule set call in progress
+type CallFrame struct {
+	// RuleSet is the name of the called rule set
+	RuleSet string `json:"rule_set"`
+	// Condition is the name of the condition whose decision called the rule set
+	Condition string `json:"condition"`
+}
+
+func (f CallFrame) String() string {
+	return fmt.Sprintf("%s called from condition '%s'", f.RuleSet, f.Condition)
+}
+
+// TraceEvent is a single step of a rules run
+type TraceEvent struct {
+	Type TraceEventType `json:"type"`
+	Time time.Time      `json:"time"`
+	// RuleSet and File identify the origin of the condition, if loaded from a library
+	RuleSet string `json:"rule_set,omitempty"`
+	File    string `json:"file,omitempty"`
+	// Line and Column locate the condition in File, or the failing javascript for errors, if known
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	Condition string `json:"condition,omitempty"`
+	Decision  string `json:"decision,omitempty"`
+	// Result is the value returned by the check function
+	Result interface{} `json:"result,omitempty"`
+	Next   string      `json:"next,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	// Call is the name of the rule set called or returned from
+	Call string `json:"call,omitempty"`
+	// CallStack lists the rule set calls in progress, innermost last
+	CallStack []CallFrame `json:"call_stack,omitempty"`
+	// Duration of the check or action function
+	Duration time.Duration `json:"duration,omitempty"`
+	// Changes lists the context fields changed by the action, or by the decision table of a result event,
+	// when audited with WithContextAudit
+	Changes []ContextChange `json:"changes,omitempty"`
+}
+
+// Trace is a structured record of a rules run that can be serialized to JSON
+type Trace struct {
+	// RuleSet is the name of the rules that have been run
+	RuleSet string `json:"rule_set"`
+	// Version of the rule set that has been run, if versioned
+	Version string `json:"version,omitempty"`
+	// RulesHash identifies the content of the rules that have been run, see Rules.Hash
+	RulesHash string        `json:"rules_hash,omitempty"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration"`
+	Events    []TraceEvent  `json:"events"`
+}
+
+func (t *Trace) record(event TraceEvent) {
+	if t == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	t.Events = append(t.Events, event)
+}
+
+// Path returns the names of the evaluated conditions in order of evaluation
+func (t *Trace) Path() []string {
+	path := []string{}
+	for _, event := range t.Events {
+		if event.Type == TraceCondition {
+			path = append(path, event.Condition)
+		}
+	}
+	return path
+}
+
+// RunRulesWithTrace runs the rules like RunRulesContext and also returns a trace of the execution.
+// The trace is returned even if the run fails, with the failure recorded as the last event.
+func (rr *RulesRunner[Context]) RunRulesWithTrace(ctx context.Context, rulesContext *Context, startCondition *Condition) (*Context, *Trace, error) {
+	trace := &Trace{RuleSet: rr.Rules.Name, Version: rr.Rules.Version, RulesHash: rr.Rules.Hash, Start: time.Now(), Events: []TraceEvent{}}
+	result, err := rr.run(ctx, rulesContext, startCondition, trace)
+	trace.Duration = time.Since(trace.Start)
+	return result, trace, err
+}
@@ -0,0 +1,85 @@
+package yabre
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRulesWithTrace(t *testing.T) {
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: "test/bre", FileSystem: testFs})
+	require.NoError(t, err)
+
+	breContext := &BreContext{RuleSet: "ruleset2"}
+	runner, err := NewRulesRunnerFromLibrary(rl, "main", breContext,
+		WithDebugCallback[BreContext](func(...any) {}))
+	require.NoError(t, err)
+
+	_, trace, err := runner.RunRulesWithTrace(context.Background(), breContext, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "main", trace.RuleSet)
+	assert.Equal(t, []string{"check_for_ruleset1", "check_for_ruleset2", "execute_ruleset2"}, trace.Path())
+
+	types := []TraceEventType{}
+	for _, event := range trace.Events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []TraceEventType{
+		TraceCondition, TraceResult, TraceNext,
+		TraceCondition, TraceResult, TraceNext,
+		TraceCondition, TraceResult, TraceAction, TraceTerminate,
+	}, types)
+
+	assert.Equal(t, false, trace.Events[1].Result)
+	assert.Equal(t, "check_for_ruleset2", trace.Events[2].Next)
+	assert.Equal(t, "main", trace.Events[3].RuleSet)
+	assert.Equal(t, "test/bre/main.yaml", trace.Events[3].File)
+
+	action := trace.Events[8]
+	assert.Equal(t, "execute_ruleset2", action.Condition)
+	assert.Equal(t, "ruleset2", action.RuleSet)
+	assert.Equal(t, "test/bre/ruleset2.yaml", action.File)
+	assert.Equal(t, "execute_ruleset2_true", action.Decision)
+
+	// trace survives a JSON round trip
+	data, err := json.Marshal(trace)
+	require.NoError(t, err)
+
+	var decoded Trace
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, trace.Path(), decoded.Path())
+	assert.Equal(t, len(trace.Events), len(decoded.Events))
+	assert.Equal(t, trace.Events[8].Decision, decoded.Events[8].Decision)
+}
+
+func TestRunRulesWithTrace_Error(t *testing.T) {
+	yamlRules := `
+name: "trace-error"
+conditions:
+  start:
+    default: true
+    check: "function() { return true; }"
+    true:
+      action: "function() { throw new Error('broken action'); }"
+      terminate: true
+`
+	library := createLibraryFromYAML(t, yamlRules, "trace-error.yaml")
+
+	rulesContext := map[string]interface{}{}
+	runner, err := NewRulesRunnerFromLibrary(library, "trace-error", &rulesContext)
+	require.NoError(t, err)
+
+	_, trace, err := runner.RunRulesWithTrace(context.Background(), &rulesContext, nil)
+	require.Error(t, err)
+	require.NotNil(t, trace)
+
+	last := trace.Events[len(trace.Events)-1]
+	assert.Equal(t, TraceError, last.Type)
+	assert.Equal(t, "start", last.Condition)
+	assert.Equal(t, "trace-error", last.RuleSet)
+	assert.Contains(t, last.Error, "broken action")
+}
@@ -0,0 +1,421 @@
+package yabre
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+)
+
+// ValidationIssue describes a single problem found in rules
+type ValidationIssue struct {
+	// File is the path of the rules file the issue was found in, if known
+	File string
+	// Line and Column locate the issue in the rules file, if known
+	Line   int
+	Column int
+	// Condition is the name of the condition the issue was found in, if any
+	Condition string
+	Message   string
+	// Warning is true for issues that don't prevent the rules from loading or running,
+	// like condition names violating the naming convention, unreachable conditions and switches without a default case
+	Warning bool
+}
+
+func (i ValidationIssue) String() string {
+	var sb strings.Builder
+	if i.Line > 0 {
+		sb.WriteString(Position{File: i.File, Line: i.Line, Column: i.Column}.String() + ": ")
+	} else if i.File != "" {
+		sb.WriteString(i.File + ": ")
+	}
+	if i.Condition != "" {
+		fmt.Fprintf(&sb, "condition '%s': ", i.Condition)
+	}
+	sb.WriteString(i.Message)
+	return sb.String()
+}
+
+// ValidationError is returned when rules fail validation; it lists all issues found
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	issues := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		issues[i] = issue.String()
+	}
+	return fmt.Sprintf("rules validation failed with %d issue(s): %s", len(e.Issues), strings.Join(issues, "; "))
+}
+
+var conditionNameRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Validate performs static checks of the rules without running them and returns a *ValidationError listing all issues found.
+// It reports dangling `next` and `call` targets, conditions unreachable from the default condition, conditions with neither branch,
+// switch conditions without a default case, javascript syntax errors, invalid decision tables, named functions colliding with each other or with the scripts, undeclared exports,
+// a missing default condition and condition names violating the naming convention.
+// Conditions of agenda rule sets don't need a default condition but can't be switches or move to other conditions.
+func (r *Rules) Validate() error {
+	issues := r.validate()
+	if len(issues) == 0 {
+		return nil
+	}
+	return &ValidationError{Issues: issues}
+}
+
+func (r *Rules) validate() []ValidationIssue {
+	issues := []ValidationIssue{}
+
+	// Scripts, with the names they declare in the global scope or in the scope of their namespace
+	declared := map[string]map[string]bool{"": {}}
+	files := map[string]string{"": r.File}
+	reserved := []string{"context"}
+	for _, source := range r.scriptSources() {
+		if source.namespace != "" {
+			declared[source.namespace] = map[string]bool{}
+			files[source.namespace] = source.file
+			reserved = append(reserved, source.namespace)
+		}
+
+		program, err := parser.ParseFile(nil, source.file, source.scripts, 0)
+		if err == nil {
+			_, err = goja.CompileAST(program, false)
+		}
+		if err != nil {
+			issue := ValidationIssue{File: source.file, Message: fmt.Sprintf("invalid scripts: %v", err)}
+			if scriptErr := newScriptError(err, source.file, "", ""); scriptErr.Line > 0 && source.at.IsValid() {
+				position := source.at.offset(scriptErr.Line, scriptErr.Column)
+				issue.Line, issue.Column = position.Line, position.Column
+			}
+			issues = append(issues, issue)
+			continue
+		}
+		for _, name := range scriptDeclarations(program) {
+			declared[source.namespace][name] = true
+		}
+		for _, name := range source.exports {
+			if !declared[source.namespace][name] {
+				issues = append(issues, ValidationIssue{File: source.file, Message: fmt.Sprintf("exported function '%s' is not declared in scripts", name)})
+			}
+		}
+	}
+
+	agenda := r.Mode == ModeAgenda
+	if len(r.Conditions) > 0 && r.DefaultCondition == nil && !agenda {
+		issues = append(issues, ValidationIssue{File: r.File, Message: "no default condition found"})
+	}
+
+	for _, condition := range r.Conditions {
+		issueAt := func(position Position, format string, args ...interface{}) {
+			issues = append(issues, ValidationIssue{File: condition.File, Line: position.Line, Column: position.Column,
+				Condition: condition.Name, Message: fmt.Sprintf(format, args...)})
+		}
+		issue := func(format string, args ...interface{}) {
+			issueAt(condition.Position, format, args...)
+		}
+
+		if !conditionNameRegex.MatchString(strings.TrimPrefix(condition.Name, condition.Namespace+".")) {
+			issues = append(issues, ValidationIssue{File: condition.File, Line: condition.Position.Line, Column: condition.Position.Column,
+				Condition: condition.Name, Message: "name should be lowercase alphanumeric symbols and '_' only", Warning: true})
+		}
+
+		if condition.Check == "" && condition.Expr == "" && condition.Table == "" {
+			issue("check function is missing")
+		} else if condition.Table != "" {
+			if _, ok := r.Tables[condition.Table]; !ok {
+				issue("table '%s' not found", condition.Table)
+			}
+		} else if condition.Check != "" {
+			if err := compileFunction(condition.Name, condition.Check); err != nil {
+				issueAt(r.errorPosition(err, condition.Name, condition.source, 0, condition.Position), "invalid check function: %v", err)
+			}
+		} else if err := compileFunction(condition.Name, exprFunction(condition.Expr)); err != nil {
+			issueAt(r.errorPosition(err, condition.Name, condition.source, condition.sourcePrefix(), condition.Position), "invalid expr: %v", err)
+		}
+
+		if agenda {
+			// agenda conditions fire their true branch and never move to other conditions
+			if condition.isSwitch() {
+				issue("switch conditions are not supported in agenda mode")
+			} else if condition.True == nil {
+				issue("true branch is not defined")
+			}
+			if condition.False != nil {
+				issue("false branch is not supported in agenda mode")
+			}
+			if condition.OnError != nil {
+				issue("on_error is not supported in agenda mode")
+			}
+			for _, decision := range condition.decisions() {
+				if decision.errorHandler {
+					continue
+				}
+				if decision.Next != "" {
+					issue("next condition of %s branch is not supported in agenda mode", decision.label())
+				}
+				if decision.Call != "" {
+					issue("call of %s branch is not supported in agenda mode", decision.label())
+				}
+			}
+		} else if condition.True == nil && condition.False == nil && condition.Cases == nil {
+			issue("neither true nor false branch is defined")
+		} else if condition.Cases != nil && len(condition.Cases) == 0 {
+			issue("switch condition has no cases")
+		} else if _, ok := condition.Cases[DefaultCase]; condition.Cases != nil && !ok {
+			issues = append(issues, ValidationIssue{File: condition.File, Line: condition.Position.Line, Column: condition.Position.Column,
+				Condition: condition.Name, Message: "switch condition has no default case, values matching no case end the run", Warning: true})
+		}
+
+		for _, decision := range condition.decisions() {
+			r.validateDecision(decision, issueAt)
+		}
+	}
+
+	for _, handler := range r.allErrorHandlers() {
+		r.validateDecision(handler.decision, func(position Position, format string, args ...interface{}) {
+			issues = append(issues, ValidationIssue{File: handler.file, Line: position.Line, Column: position.Column, Message: fmt.Sprintf(format, args...)})
+		})
+		if agenda {
+			issues = append(issues, ValidationIssue{File: handler.file, Message: "on_error is not supported in agenda mode"})
+		}
+	}
+
+	for name, table := range r.Tables {
+		if code, err := tableFunction(table); err != nil {
+			issues = append(issues, ValidationIssue{File: r.File, Message: fmt.Sprintf("table '%s': %v", name, err)})
+		} else if err := compileFunction(name, code); err != nil {
+			issues = append(issues, ValidationIssue{File: r.File, Message: fmt.Sprintf("table '%s': invalid javascript: %v", name, err)})
+		}
+	}
+
+	// Functions must not share a registry key, and named functions must not shadow the scripts of their scope
+	functionNames := map[string]map[string][]string{}
+	functions, _ := r.functions() // invalid set assignments are reported with their conditions
+	issues = append(issues, keyCollisions(functions)...)
+	for _, function := range functions {
+		if _, name, err := parseFunction(function.key, function.code); err == nil && name != "" {
+			if functionNames[function.namespace] == nil {
+				functionNames[function.namespace] = map[string][]string{}
+			}
+			functionNames[function.namespace][name] = append(functionNames[function.namespace][name], function.key)
+		}
+	}
+	for namespace, names := range declared {
+		// only the shared scripts declare global names
+		engineNames := reserved
+		if namespace != "" {
+			engineNames = nil
+		}
+		issues = append(issues, nameCollisions(files[namespace], functionNames[namespace], names, engineNames)...)
+	}
+
+	// Reachability can only be checked from the default condition
+	if r.DefaultCondition != nil && !agenda {
+		reachable := r.reachableConditions(r.DefaultCondition.Name)
+		// the on_error decisions of the rule sets can be taken from any of their conditions
+		for _, handler := range r.allErrorHandlers() {
+			for name := range r.reachableConditions(handler.decision.Next) {
+				reachable[name] = true
+			}
+			if entry, ok := r.entry(handler.decision.Call); handler.decision.Call != "" && ok {
+				for name := range r.reachableConditions(entry) {
+					reachable[name] = true
+				}
+			}
+		}
+		for name, condition := range r.Conditions {
+			if !reachable[name] {
+				issues = append(issues, ValidationIssue{File: condition.File, Line: condition.Position.Line, Column: condition.Position.Column,
+					Condition: name, Message: "unreachable from default condition", Warning: true})
+			}
+		}
+	}
+
+	sortIssues(issues)
+	return issues
+}
+
+// validateDecision reports invalid functions, assignments and targets of a decision at the position of the decision,
+// or of the syntax error of its action
+func (r *Rules) validateDecision(decision *Decision, issueAt func(position Position, format string, args ...interface{})) {
+	issue := func(format string, args ...interface{}) {
+		issueAt(decision.Position, format, args...)
+	}
+	if decision.Action != "" {
+		if err := compileFunction(decision.Name, decision.Action); err != nil {
+			issueAt(r.errorPosition(err, decision.Name, decision.source, 0, decision.Position), "invalid %s action function: %v", decision.label(), err)
+		}
+	}
+	if len(decision.Set) > 0 {
+		if code, err := setFunction(decision.Set, decision.params()...); err != nil {
+			issue("invalid %s set: %v", decision.label(), err)
+		} else if err := compileFunction(decision.Name, code); err != nil {
+			issue("invalid %s set: %v", decision.label(), err)
+		}
+	}
+	if decision.Next != "" {
+		if _, ok := r.Conditions[decision.Next]; !ok {
+			issue("next condition '%s' of %s branch not found", decision.Next, decision.label())
+		}
+	}
+	if decision.Call != "" {
+		if _, ok := r.entry(decision.Call); !ok {
+			issue("called rule set '%s' of %s branch not found or has no default condition", decision.Call, decision.label())
+		}
+	}
+	if decision.Retry > 0 && !decision.errorHandler {
+		issue("retry of %s branch is only supported by on_error", decision.label())
+	}
+}
+
+// reachableConditions returns the names of all conditions reachable from the given one via `next`
+func (r *Rules) reachableConditions(start string) map[string]bool {
+	reachable := map[string]bool{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		condition, ok := r.Conditions[name]
+		if !ok || reachable[name] {
+			continue
+		}
+		reachable[name] = true
+
+		for _, decision := range condition.decisions() {
+			if decision.Next != "" {
+				queue = append(queue, decision.Next)
+			}
+			if entry, ok := r.entry(decision.Call); decision.Call != "" && ok {
+				queue = append(queue, entry)
+			}
+		}
+	}
+	return reachable
+}
+
+// compileFunction checks the syntax of a check or action function.
+// It compiles the parsed function like the runner does, so syntax errors keep their position.
+func compileFunction(name, funcCode string) error {
+	program, _, err := parseFunction(name, funcCode)
+	if err == nil {
+		_, err = goja.CompileAST(program, false)
+	}
+	return err
+}
+
+// errorPosition returns the position in the rules file of the syntax error of the function named key, see locateError
+func (r *Rules) errorPosition(err error, key string, source Position, prefix int, at Position) Position {
+	return r.locateError(newScriptError(err, "", "", key), key, source, prefix, at).Position
+}
+
+// blocking returns the issues of the validation error that aren't warnings
+func (e *ValidationError) blocking() []ValidationIssue {
+	found := []ValidationIssue{}
+	for _, issue := range e.Issues {
+		if !issue.Warning {
+			found = append(found, issue)
+		}
+	}
+	return found
+}
+
+func sortIssues(issues []ValidationIssue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+		if issues[i].Condition != issues[j].Condition {
+			return issues[i].Condition < issues[j].Condition
+		}
+		return issues[i].Message < issues[j].Message
+	})
+}
+
+// ValidateAll validates every rule set of the library that no other rule set requires, together with its dependencies,
+// and returns a *ValidationError listing all issues found.
+// Rule sets required by others are validated as part of the rule sets requiring them.
+func (rl *RulesLibrary) ValidateAll() error {
+	return rl.current().validateAll()
+}
+
+func (rl *RulesLibrary) validateAll() error {
+	// every version of a rule set is validated, unless it is the version other rule sets require
+	required := map[string]bool{}
+	for _, versions := range rl.versions {
+		for _, v := range versions {
+			for _, dep := range v.require {
+				if selected, err := rl.selectVersion(dep); err == nil {
+					required[selected.path] = true
+				}
+			}
+		}
+	}
+
+	roots := []ruleSetVersion{}
+	for _, versions := range rl.versions {
+		for _, v := range versions {
+			if !required[v.path] {
+				roots = append(roots, v)
+			}
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].path < roots[j].path })
+
+	seen := map[ValidationIssue]bool{}
+	issues := []ValidationIssue{}
+	for _, root := range roots {
+		var found []ValidationIssue
+		rules, err := rl.loadRules(root.reference())
+		if err != nil {
+			found = []ValidationIssue{{File: root.path, Message: err.Error()}}
+		} else {
+			found = rules.validate()
+		}
+
+		// shared dependencies would be reported once per rule set requiring them
+		for _, issue := range found {
+			if !seen[issue] {
+				seen[issue] = true
+				issues = append(issues, issue)
+			}
+		}
+	}
+
+	// rule sets requiring each other aren't validated as no other rule set requiring them is,
+	// every cycle is reported once, by its rule set with the lowest name
+	for _, versions := range rl.versions {
+		for _, v := range versions {
+			_, _, err := rl.resolveDependencies(v.reference())
+			var cycleError *DependencyCycleError
+			if errors.As(err, &cycleError) && cycleError.Cycle[0] == v.name && v.name == minString(cycleError.Cycle) {
+				issue := ValidationIssue{File: v.path, Message: err.Error()}
+				if !seen[issue] {
+					seen[issue] = true
+					issues = append(issues, issue)
+				}
+			}
+		}
+	}
+
+	if len(issues) == 0 {
+		return nil
+	}
+	sortIssues(issues)
+	return &ValidationError{Issues: issues}
+}
+
+func minString(values []string) string {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
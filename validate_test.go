@@ -0,0 +1,289 @@
+package yabre
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidate_ValidRules(t *testing.T) {
+	for _, file := range []string{"test/loan_approval.yaml", "test/go_rules.yaml", "test/update_context.yaml"} {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		var rules Rules
+		require.NoError(t, yaml.Unmarshal(data, &rules))
+		assert.NoError(t, rules.Validate(), file)
+	}
+}
+
+func TestValidate_Issues(t *testing.T) {
+	yamlRules := `
+name: "invalid-rules"
+scripts: |
+  function helper( {
+conditions:
+  start:
+    default: true
+    check: "function() { return true; }"
+    true:
+      next: missing
+    false:
+      next: bad_check
+  bad_check:
+    check: "function() { return"
+    true:
+      action: "function() { context.x = ; }"
+      terminate: true
+  noBranches:
+    check: "function() { return true; }"
+  orphan:
+    true:
+      terminate: true
+`
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(yamlRules), &rules))
+
+	err := rules.Validate()
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+
+	messages := map[string][]string{}
+	for _, issue := range validationErr.Issues {
+		messages[issue.Condition] = append(messages[issue.Condition], issue.Message)
+	}
+
+	assert.Len(t, messages[""], 1)
+	assert.Contains(t, messages[""][0], "invalid scripts")
+	assert.Equal(t, []string{"next condition 'missing' of true branch not found"}, messages["start"])
+	assert.Len(t, messages["bad_check"], 2)
+	assert.Contains(t, messages["bad_check"][0], "invalid check function")
+	assert.Contains(t, messages["bad_check"][1], "invalid true action function")
+	assert.Equal(t, []string{
+		"name should be lowercase alphanumeric symbols and '_' only",
+		"neither true nor false branch is defined",
+		"unreachable from default condition",
+	}, messages["noBranches"])
+	assert.Equal(t, []string{
+		"check function is missing",
+		"unreachable from default condition",
+	}, messages["orphan"])
+}
+
+func TestValidate_MissingDefault(t *testing.T) {
+	yamlRules := `
+name: "no-default"
+conditions:
+  first:
+    check: "function() { return true; }"
+    true:
+      terminate: true
+`
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(yamlRules), &rules))
+
+	err := rules.Validate()
+	require.Error(t, err)
+	assert.Equal(t, "rules validation failed with 1 issue(s): no default condition found", err.Error())
+}
+
+func TestValidate_NameCollisions(t *testing.T) {
+	yamlRules := `
+name: "collisions"
+scripts: |
+  function helper() { return true; }
+conditions:
+  start:
+    default: true
+    check: "function check() { return helper(); }"
+    true:
+      next: other
+  other:
+    check: "function check() { return true; }"
+    true:
+      action: "function helper() { context.x = 1; }"
+      next: other_true
+  other_true:
+    check: "function() { return true; }"
+    true:
+      terminate: true
+`
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(yamlRules), &rules))
+
+	err := rules.Validate()
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []ValidationIssue{
+		{Message: "function key 'other_true' is used by action of condition other, check of condition other_true"},
+		{Condition: "other_true", Message: "function name 'helper' shadows a scripts declaration"},
+	}, validationErr.Issues)
+}
+
+func TestValidateAll_ValidLibrary(t *testing.T) {
+	for _, basePath := range []string{"./test", "./test/bre", "./test/scoped", "./test/calls", "./test/dmn"} {
+		rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: basePath})
+		require.NoError(t, err)
+		assert.NoError(t, rl.ValidateAll(), basePath)
+	}
+}
+
+func TestValidateAll_ReportsFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(tempDir+"/helpers.yaml", []byte(`
+name: helpers
+scripts: |
+  function broken( {
+conditions:
+  helper_condition:
+    check: "function() { return true; }"
+    true:
+      next: nowhere
+`), 0644))
+	require.NoError(t, os.WriteFile(tempDir+"/main.yaml", []byte(`
+name: main
+require:
+  - helpers
+conditions:
+  start:
+    default: true
+    check: "function() { return true; }"
+    true:
+      next: helper_condition
+`), 0644))
+	require.NoError(t, os.WriteFile(tempDir+"/other.yaml", []byte(`
+name: other
+require:
+  - missing
+`), 0644))
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{BasePath: tempDir})
+	require.NoError(t, err)
+
+	err = rl.ValidateAll()
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	require.Len(t, validationErr.Issues, 3)
+
+	assert.Equal(t, "helpers.yaml", validationErr.Issues[0].File)
+	assert.Contains(t, validationErr.Issues[0].Message, "invalid scripts")
+
+	assert.Equal(t, "helpers.yaml", validationErr.Issues[1].File)
+	assert.Equal(t, "helper_condition", validationErr.Issues[1].Condition)
+	assert.Equal(t, "next condition 'nowhere' of true branch not found", validationErr.Issues[1].Message)
+
+	assert.Equal(t, "other.yaml", validationErr.Issues[2].File)
+	assert.Contains(t, validationErr.Issues[2].Message, "rule set missing not found")
+}
+
+func TestValidateAll_NamespacedRuleSets(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"main.yaml": {Data: []byte(`
+name: main
+require:
+  - shipping
+scripts: |
+  var shipping = {};
+conditions:
+  start:
+    default: true
+    check: "function() { return shipping.rate() > 0; }"
+    true:
+      next: shipping.start
+`)},
+		"shipping.yaml": {Data: []byte(`
+name: shipping
+namespaced: true
+exports:
+  - rate
+  - missing
+scripts: |
+  function rate() { return 2.5; }
+conditions:
+  start:
+    check: "function rate() { return true; }"
+    true:
+      terminate: true
+`)},
+	}
+
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+
+	err = rl.ValidateAll()
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []ValidationIssue{
+		{File: "main.yaml", Message: "scripts declare 'shipping' which is reserved by the engine"},
+		{File: "shipping.yaml", Message: "exported function 'missing' is not declared in scripts"},
+		{File: "shipping.yaml", Condition: "shipping.start", Message: "function name 'rate' shadows a scripts declaration"},
+	}, validationErr.Issues)
+}
+
+func TestValidate_SwitchCases(t *testing.T) {
+	yamlRules := `
+name: "switch"
+conditions:
+  route:
+    default: true
+    check: "function() { return context.plan; }"
+    cases:
+      gold:
+        next: missing
+      silver:
+        action: "function() { context.x = ; }"
+      default:
+        next: fallback
+  fallback:
+    check: "function() { return true; }"
+    true:
+      terminate: true
+`
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(yamlRules), &rules))
+
+	err := rules.Validate()
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	require.Len(t, validationErr.Issues, 2)
+	assert.Contains(t, validationErr.Issues[0].Message, "invalid case 'silver' action function")
+	assert.Equal(t, "next condition 'missing' of case 'gold' branch not found", validationErr.Issues[1].Message)
+}
+
+func TestValidate_SwitchWithoutDefault(t *testing.T) {
+	yamlRules := `
+name: "switch"
+conditions:
+  route:
+    default: true
+    check: "function() { return context.plan; }"
+    cases:
+      gold:
+        terminate: true
+`
+	var rules Rules
+	require.NoError(t, yaml.Unmarshal([]byte(yamlRules), &rules))
+
+	err := rules.Validate()
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []ValidationIssue{{Condition: "route",
+		Message: "switch condition has no default case, values matching no case end the run", Warning: true}}, validationErr.Issues)
+}
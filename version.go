@@ -0,0 +1,218 @@
+package yabre
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a parsed rule set version: up to three numeric components and an optional pre-release, e.g. `1.2.0-beta`
+type version struct {
+	numbers    []int
+	prerelease string
+}
+
+func parseVersion(s string) (version, error) {
+	v := version{}
+	numbers, prerelease, _ := strings.Cut(strings.TrimPrefix(s, "v"), "-")
+	v.prerelease = prerelease
+
+	parts := strings.Split(numbers, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %s", s)
+	}
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %s", s)
+		}
+		v.numbers = append(v.numbers, n)
+	}
+	return v, nil
+}
+
+// component returns the i-th number of the version, 0 when omitted
+func (v version) component(i int) int {
+	if i < len(v.numbers) {
+		return v.numbers[i]
+	}
+	return 0
+}
+
+// compare returns -1, 0 or 1 when v is lower than, equal to or greater than other.
+// Omitted numbers are 0 and pre-releases are lower than their release.
+func (v version) compare(other version) int {
+	for i := 0; i < 3; i++ {
+		if a, b := v.component(i), other.component(i); a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	}
+	return comparePrereleases(v.prerelease, other.prerelease)
+}
+
+// comparePrereleases compares pre-releases by their dot separated identifiers, like semver: numeric identifiers
+// are compared numerically and are lower than alphanumeric ones, and a pre-release with fewer identifiers is lower
+func comparePrereleases(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		na, errA := strconv.Atoi(as[i])
+		nb, errB := strconv.Atoi(bs[i])
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case errA == nil && errB != nil:
+			return -1
+		case errA != nil && errB == nil:
+			return 1
+		case errA != nil && errB != nil && as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// sameNumbers reports whether two versions have the same numbers, regardless of their pre-releases
+func (v version) sameNumbers(other version) bool {
+	return v.component(0) == other.component(0) && v.component(1) == other.component(1) && v.component(2) == other.component(2)
+}
+
+// compareVersions compares two version strings, see version.compare; unparsable versions are lowest
+func compareVersions(a, b string) int {
+	va, errA := parseVersion(a)
+	vb, errB := parseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.compare(vb)
+}
+
+// versionConstraint selects versions of a rule set. It is a space separated list of ranges that must all match:
+// `1.2.0` (exact), `1.2` (any 1.2.x), `^1.2` (1.2 or later before 2), `~1.2.3` (1.2.3 or later before 1.3),
+// `~1` (any 1.x), `>=1.0`, `>1.0`, `<2.0`, `<=2.0`, `=1.2.0` or `*` and `latest` for any version.
+// Like npm, `^` keeps the first non-zero number: `^0.2` is 0.2 or later before 0.3.
+// Pre-releases only match constraints naming a pre-release of the same version, e.g. `>=2.0.0-beta` matches 2.0.0-rc1
+// but not 2.1.0-rc1.
+type versionConstraint struct {
+	ranges []func(version) bool
+	// versions named with a pre-release by the constraint
+	prereleases []version
+}
+
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	constraint := versionConstraint{}
+	for _, part := range strings.Fields(s) {
+		if part == "*" || part == "latest" {
+			continue
+		}
+
+		operator := ""
+		for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(part, prefix) {
+				operator, part = prefix, strings.TrimPrefix(part, prefix)
+				break
+			}
+		}
+		bound, err := parseVersion(part)
+		if err != nil {
+			return versionConstraint{}, fmt.Errorf("invalid version constraint %s: %w", s, err)
+		}
+		if bound.prerelease != "" {
+			constraint.prereleases = append(constraint.prereleases, bound)
+		}
+
+		var match func(version) bool
+		switch operator {
+		case ">=":
+			match = func(v version) bool { return v.compare(bound) >= 0 }
+		case "<=":
+			match = func(v version) bool { return v.compare(bound) <= 0 }
+		case ">":
+			match = func(v version) bool { return v.compare(bound) > 0 }
+		case "<":
+			match = func(v version) bool { return v.compare(bound) < 0 }
+		case "^":
+			// keep the numbers up to the first non-zero one, or all of them when they are all zero
+			pinned := len(bound.numbers)
+			for i, n := range bound.numbers {
+				if n != 0 {
+					pinned = i + 1
+					break
+				}
+			}
+			match = func(v version) bool { return v.compare(bound) >= 0 && bound.samePrefix(v, pinned) }
+		case "~":
+			// keep the major and minor numbers, or only the major one when the minor is omitted
+			pinned := min(len(bound.numbers), 2)
+			match = func(v version) bool { return v.compare(bound) >= 0 && bound.samePrefix(v, pinned) }
+		case "=":
+			match = func(v version) bool { return v.compare(bound) == 0 }
+		default:
+			// partial versions match all their patch or minor versions
+			match = func(v version) bool {
+				return bound.samePrefix(v, len(bound.numbers)) && (len(bound.numbers) < 3 || v.prerelease == bound.prerelease)
+			}
+		}
+		constraint.ranges = append(constraint.ranges, match)
+	}
+	return constraint, nil
+}
+
+// samePrefix reports whether the first n numbers of v and other are the same
+func (v version) samePrefix(other version, n int) bool {
+	for i := 0; i < n; i++ {
+		if v.component(i) != other.component(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c versionConstraint) matches(v version) bool {
+	if v.prerelease != "" {
+		named := false
+		for _, prerelease := range c.prereleases {
+			named = named || prerelease.sameNumbers(v)
+		}
+		if !named {
+			return false
+		}
+	}
+	for _, match := range c.ranges {
+		if !match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitRuleSetReference splits a rule set reference `<name>@<version constraint>`, as used by `require`
+// and to load rule sets from a library, into the rule set name and the constraint, empty for the latest version
+func splitRuleSetReference(reference string) (string, string) {
+	name, constraint, _ := strings.Cut(reference, "@")
+	return strings.TrimSpace(name), strings.TrimSpace(constraint)
+}
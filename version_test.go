@@ -0,0 +1,207 @@
+package yabre
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		matches    bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		{"1.2.0", "1.2.1", false},
+		{"1.2", "1.2.7", true},
+		{"1", "1.9.0", true},
+		{"1", "2.0.0", false},
+		{"=1.2.0", "1.2.0", true},
+		{"^1.2", "1.9.0", true},
+		{"^1.2", "1.1.0", false},
+		{"^1.2", "2.0.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1", "1.5.0", true},
+		{"~1", "2.0.0", false},
+		{"~1.2", "1.2.9", true},
+		{"~1.2", "1.3.0", false},
+		{"^0.2", "0.2.5", true},
+		{"^0.2", "0.3.0", false},
+		{"^0.2.3", "0.2.2", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0", "0.9.0", true},
+		{"^0", "1.0.0", false},
+		{">=1.0 <2.0", "1.5.0", true},
+		{">=1.0 <2.0", "2.0.0", false},
+		{">1.0", "1.0.0", false},
+		{"<=1.0", "1.0.0", true},
+		{"*", "0.0.1", true},
+		{"latest", "3.0.0", true},
+		// pre-releases only match constraints naming a pre-release of the same version
+		{"2", "2.0.0-beta", false},
+		{"*", "2.0.0-beta", false},
+		{"<1.0.0", "1.0.0-beta", false},
+		{"2.0.0-beta", "2.0.0-beta", true},
+		{">=2.0.0-beta", "2.0.0-rc.1", true},
+		{">=2.0.0-beta", "2.0.0", true},
+		{">=2.0.0-beta", "2.1.0-rc.1", false},
+		{"^2.0.0-rc.1", "2.0.0-rc.2", true},
+	}
+	for _, test := range tests {
+		constraint, err := parseVersionConstraint(test.constraint)
+		require.NoError(t, err)
+		v, err := parseVersion(test.version)
+		require.NoError(t, err)
+		assert.Equal(t, test.matches, constraint.matches(v), "%s matching %s", test.constraint, test.version)
+	}
+
+	_, err := parseVersionConstraint("^one")
+	assert.EqualError(t, err, "invalid version constraint ^one: invalid version one")
+
+	assert.Equal(t, -1, compareVersions("1.2.0-beta", "1.2.0"))
+	assert.Equal(t, 1, compareVersions("1.10.0", "1.9.0"))
+	assert.Equal(t, 0, compareVersions("v1.2", "1.2.0"))
+	assert.Equal(t, -1, compareVersions("1.0.0-rc.2", "1.0.0-rc.10"))
+	assert.Equal(t, -1, compareVersions("1.0.0-alpha", "1.0.0-alpha.1"))
+	assert.Equal(t, -1, compareVersions("1.0.0-1", "1.0.0-alpha"))
+	assert.Equal(t, 1, compareVersions("1.0.0-beta", "1.0.0-alpha.5"))
+}
+
+func ratesRules(version, rate string) string {
+	return `
+name: rates
+version: ` + version + `
+conditions:
+  rate:
+    default: true
+    expr: "true"
+    true:
+      set:
+        Rate: ` + rate + `
+      terminate: true
+`
+}
+
+func versionedPricingRules(version, require string) string {
+	return `
+name: pricing
+version: ` + version + `
+require:
+  - ` + require + `
+conditions:
+  start:
+    default: true
+    expr: "true"
+    true:
+      next: rate
+`
+}
+
+func TestRulesLibrary_Versions(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"rates_1_0.yaml":    {Data: []byte(ratesRules("1.0.0", "1"))},
+		"rates_1_2.yaml":    {Data: []byte(ratesRules("1.2.0", "1.2"))},
+		"rates_2_0.yaml":    {Data: []byte(ratesRules("2.0.0", "2"))},
+		"pricing_1_0.yaml":  {Data: []byte(versionedPricingRules("1.0.0", "rates@1.0.0"))},
+		"pricing_1_1.yaml":  {Data: []byte(versionedPricingRules("1.1.0", "rates@^1"))},
+		"pricing_2_0.yaml":  {Data: []byte(versionedPricingRules("2.0.0-rc1", "rates"))},
+		"pricing_1_10.yaml": {Data: []byte(versionedPricingRules("1.10.0", "rates@~1.0"))},
+	}
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fileSystem})
+	require.NoError(t, err)
+	require.NoError(t, rl.ValidateAll())
+
+	assert.Equal(t, []string{"1.0.0", "1.1.0", "1.10.0", "2.0.0-rc1"}, rl.Versions("pricing"))
+	assert.Equal(t, []string{"1.0.0", "1.2.0", "2.0.0"}, rl.Versions("rates"))
+	assert.Nil(t, rl.Versions("missing"))
+	// pre-releases aren't the latest version
+	assert.Equal(t, "pricing_1_10.yaml", rl.GetRuleNamesAndPaths()["pricing"])
+
+	tests := []struct {
+		reference string
+		version   string
+		rate      float64
+	}{
+		{"pricing", "1.10.0", 1},
+		{"pricing@latest", "1.10.0", 1},
+		{"pricing@*", "1.10.0", 1},
+		{"pricing@2.0.0-rc1", "2.0.0-rc1", 2},
+		{"pricing@>=2.0.0-rc0", "2.0.0-rc1", 2},
+		{"pricing@1.0.0", "1.0.0", 1},
+		{"pricing@1.1", "1.1.0", 1.2},
+		{"pricing@^1", "1.10.0", 1},
+		{"pricing@<2", "1.10.0", 1},
+		{"pricing@<1.5", "1.1.0", 1.2},
+		{"rates@1", "1.2.0", 1.2},
+		{"rates@~1", "1.2.0", 1.2},
+	}
+	for _, test := range tests {
+		rulesContext := ReloadContext{}
+		runner, err := NewRulesRunnerFromLibrary(rl, test.reference, &rulesContext)
+		require.NoError(t, err, test.reference)
+		result, trace, err := runner.RunRulesWithTrace(context.Background(), &rulesContext, nil)
+		require.NoError(t, err, test.reference)
+		assert.Equal(t, test.rate, result.Rate, test.reference)
+		assert.Equal(t, test.version, trace.Version, test.reference)
+	}
+
+	_, err = rl.LoadRules("pricing@3")
+	assert.ErrorContains(t, err, "rule set pricing has no version matching 3")
+	_, err = rl.LoadRules("pricing@2")
+	assert.ErrorContains(t, err, "rule set pricing has no version matching 2")
+
+	// rule sets with pre-releases only load their latest pre-release
+	rl, err = NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{
+		"rates_beta.yaml": {Data: []byte(ratesRules("1.0.0-beta", "1"))},
+		"rates_rc.yaml":   {Data: []byte(ratesRules("1.0.0-rc1", "2"))},
+	}})
+	require.NoError(t, err)
+	rates, err := rl.LoadRules("rates")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0-rc1", rates.Version)
+}
+
+func TestRulesLibrary_VersionErrors(t *testing.T) {
+	_, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{
+		"a.yaml": {Data: []byte(ratesRules("1.0.0", "1"))},
+		"b.yaml": {Data: []byte(ratesRules("1.0", "2"))},
+	}})
+	assert.ErrorContains(t, err, "duplicate rule set rates version 1.0")
+
+	_, err = NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{
+		"a.yaml": {Data: []byte(ratesRules("1.0.0", "1"))},
+		"b.yaml": {Data: []byte(ratesRules(`""`, "2"))},
+	}})
+	assert.ErrorContains(t, err, "rule set rates has files with and without version")
+
+	_, err = NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{
+		"a.yaml": {Data: []byte(ratesRules("one", "1"))},
+	}})
+	assert.ErrorContains(t, err, "invalid version one")
+
+	_, err = NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{
+		"a.yaml": {Data: []byte(versionedPricingRules("1.0.0", "rates@^x"))},
+	}})
+	assert.ErrorContains(t, err, "invalid require rates@^x")
+
+	// a rule set can only be merged in a single version
+	rl, err := NewRulesLibrary(RulesLibrarySettings{FileSystem: fstest.MapFS{
+		"rates_1.yaml":     {Data: []byte(ratesRules("1.0.0", "1"))},
+		"rates_2.yaml":     {Data: []byte(ratesRules("2.0.0", "2"))},
+		"discounts.yaml":   {Data: []byte("name: discounts\nrequire: [rates@1]\nconditions: {}\n")},
+		"pricing_1.yaml":   {Data: []byte(versionedPricingRules("1.0.0", "discounts\n  - rates@2"))},
+		"unversioned.yaml": {Data: []byte("name: unversioned\nconditions: {}\n")},
+	}})
+	require.NoError(t, err)
+	_, err = rl.LoadRules("pricing")
+	assert.ErrorContains(t, err, "rule set rates is required in versions 1.0.0 and 2.0.0")
+
+	_, err = rl.LoadRules("unversioned@1")
+	assert.ErrorContains(t, err, "rule set unversioned has no versions, can't select 1")
+}